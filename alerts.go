@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// PRICE ALERTS
+// Rules are checked against every stock quote and crypto price the app
+// receives, from any page. A rule fires once and stays triggered until it is
+// re-armed. Rules, and the prices the rise/fall rules need, are saved in
+// alerts.json next to the config file.
+
+const (
+	alertStock  = "stock"
+	alertCrypto = "crypto"
+)
+
+// toastDuration is how long an alert notification stays on screen
+const toastDuration = 6 * time.Second
+
+// alertFields are the values a rule can compare, by kind of symbol
+var alertFields = map[string][]string{
+	alertStock:  {"price", "open", "high", "low", "close", "change%", "volume"},
+	alertCrypto: {"price"},
+}
+
+var alertOps = map[string]string{">": ">", ">=": ">=", "<": "<", "<=": "<=", "above": ">", "below": "<"}
+
+// alertRule is a parsed rule: either "SYMBOL FIELD OP VALUE" or
+// "SYMBOL rises|falls VALUE% in WINDOW".
+type alertRule struct {
+	Text      string    `json:"rule"`
+	Kind      string    `json:"kind"`
+	Symbol    string    `json:"symbol"`
+	Field     string    `json:"field"`
+	Op        string    `json:"op"`
+	Value     float64   `json:"value"`
+	Window    Duration  `json:"window"`
+	Triggered time.Time `json:"triggered,omitempty"`
+	// Last is the value the rule was last checked against
+	Last *float64 `json:"last,omitempty"`
+}
+
+// sameAlert reports whether r is the rule other was, ignoring the state that
+// changes as quotes arrive
+func (r alertRule) sameAlert(other alertRule) bool {
+	r.Triggered, r.Last = other.Triggered, other.Last
+	return r == other
+}
+
+// parseAlertRule reads a rule such as "AAPL close > 200" or "bitcoin falls
+// 5% in 24h". Stocks are told from coins by their shape: a ticker is written
+// in capitals, as in AAPL, or with a $, as in $snow. Anything else is a coin.
+func parseAlertRule(text string) (alertRule, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 4 {
+		return alertRule{}, fmt.Errorf("expected SYMBOL FIELD OP VALUE or SYMBOL rises|falls N%% in WINDOW")
+	}
+	rule := alertRule{Text: strings.Join(fields, " ")}
+
+	symbol, dollar := strings.CutPrefix(fields[0], "$")
+	if dollar || tickerPattern.MatchString(symbol) {
+		rule.Kind, rule.Symbol = alertStock, strings.ToUpper(symbol)
+		if !tickerPattern.MatchString(rule.Symbol) {
+			return alertRule{}, fmt.Errorf("%q is not a ticker", fields[0])
+		}
+	} else {
+		rule.Kind, rule.Symbol = alertCrypto, strings.ToLower(symbol)
+	}
+
+	switch direction := strings.ToLower(fields[1]); direction {
+	case "rises", "falls":
+		if len(fields) != 5 || strings.ToLower(fields[3]) != "in" {
+			return alertRule{}, fmt.Errorf("expected %s %s N%% in WINDOW", fields[0], direction)
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "%"), 64)
+		if err != nil || percent <= 0 {
+			return alertRule{}, fmt.Errorf("%q is not a positive percentage", fields[2])
+		}
+		window, err := parseWindow(fields[4])
+		if err != nil {
+			return alertRule{}, err
+		}
+		rule.Field, rule.Op, rule.Value, rule.Window = "price", direction, percent, Duration{window}
+	default:
+		if len(fields) != 4 {
+			return alertRule{}, fmt.Errorf("expected SYMBOL FIELD OP VALUE")
+		}
+		rule.Field = strings.ToLower(fields[1])
+		if !slices.Contains(alertFields[rule.Kind], rule.Field) {
+			if rule.Kind == alertCrypto {
+				return alertRule{}, fmt.Errorf("coin alerts can only watch price; write stock tickers in capitals or with a $, e.g. $%s", strings.ToUpper(rule.Symbol))
+			}
+			return alertRule{}, fmt.Errorf("stock alerts can watch %s", strings.Join(alertFields[rule.Kind], ", "))
+		}
+		op, ok := alertOps[strings.ToLower(fields[2])]
+		if !ok {
+			return alertRule{}, fmt.Errorf("%q is not one of >, >=, <, <=, above or below", fields[2])
+		}
+		value, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSuffix(fields[3], "%"), ",", ""), 64)
+		if err != nil {
+			return alertRule{}, fmt.Errorf("%q is not a number", fields[3])
+		}
+		rule.Op, rule.Value = op, value
+	}
+	return rule, nil
+}
+
+// parseWindow is time.ParseDuration that also takes whole days, e.g. 7d
+func parseWindow(text string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	window, err := time.ParseDuration(text)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("%q is not a window such as 30m, 24h or 7d", text)
+	}
+	return window, nil
+}
+
+func (r alertRule) key() string {
+	return r.Kind + ":" + r.Symbol
+}
+
+func (r alertRule) trend() bool {
+	return r.Op == "rises" || r.Op == "falls"
+}
+
+// alertQuote is a fresh price of one symbol, with the values rules can
+// compare
+type alertQuote struct {
+	Kind   string
+	Symbol string
+	Values map[string]float64
+	At     time.Time
+}
+
+func stockAlertQuote(stock StockData, at time.Time) alertQuote {
+	return alertQuote{Kind: alertStock, Symbol: strings.ToUpper(stock.Ticker), At: at, Values: map[string]float64{
+		"price":   stock.Close,
+		"open":    stock.Open,
+		"high":    stock.High,
+		"low":     stock.Low,
+		"close":   stock.Close,
+		"change%": stockChangePercent(stock),
+		"volume":  float64(stock.Volume),
+	}}
+}
+
+func (q alertQuote) key() string {
+	return q.Kind + ":" + q.Symbol
+}
+
+type pricePoint struct {
+	At    time.Time `json:"at"`
+	Price float64   `json:"price"`
+}
+
+// alertBook holds the rules and, for symbols with rise/fall rules, the
+// prices seen lately.
+type alertBook struct {
+	Alerts  []alertRule             `json:"alerts"`
+	History map[string][]pricePoint `json:"history,omitempty"`
+}
+
+func loadAlertBook(path string) (*alertBook, error) {
+	book := &alertBook{}
+	if _, err := loadState(path, book); err != nil {
+		return nil, err
+	}
+	if book.History == nil {
+		book.History = make(map[string][]pricePoint)
+	}
+	return book, nil
+}
+
+// baseline is the price the window of a rise/fall rule starts from: the last
+// one seen at or before the start of the window, or else the earliest one
+// seen since.
+func baseline(history []pricePoint, since time.Time) (pricePoint, bool) {
+	if len(history) == 0 {
+		return pricePoint{}, false
+	}
+	base := history[0]
+	for _, p := range history {
+		if p.At.After(since) {
+			break
+		}
+		base = p
+	}
+	return base, true
+}
+
+// check reports the value r watches in q and whether it meets the rule.
+// Rise/fall rules measure the percentage change from the baseline.
+func (r alertRule) check(q alertQuote, history []pricePoint) (float64, bool) {
+	value, ok := q.Values[r.Field]
+	if !ok {
+		return 0, false
+	}
+	if r.trend() {
+		base, ok := baseline(history, q.At.Add(-r.Window.Duration))
+		if !ok || base.Price == 0 {
+			return 0, false
+		}
+		change := (value - base.Price) / base.Price * 100
+		if r.Op == "falls" {
+			return change, change <= -r.Value
+		}
+		return change, change >= r.Value
+	}
+	switch r.Op {
+	case ">":
+		return value, value > r.Value
+	case ">=":
+		return value, value >= r.Value
+	case "<":
+		return value, value < r.Value
+	case "<=":
+		return value, value <= r.Value
+	}
+	return value, false
+}
+
+// message describes a rule that has just fired
+func (r alertRule) message(value float64) string {
+	switch r.Op {
+	case "rises":
+		return fmt.Sprintf("%s rose %.2f%% in %s", r.Symbol, value, formatWindow(r.Window.Duration))
+	case "falls":
+		return fmt.Sprintf("%s fell %.2f%% in %s", r.Symbol, -value, formatWindow(r.Window.Duration))
+	}
+	return fmt.Sprintf("%s %s is %s (%s %s %s)", r.Symbol, r.Field, formatAlertValue(math.Round(value*100)/100), r.Field, r.Op, formatAlertValue(r.Value))
+}
+
+func formatWindow(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+func formatAlertValue(value float64) string {
+	if value == math.Trunc(value) && math.Abs(value) >= 1000 {
+		return strconv.FormatFloat(value, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// observe checks every armed rule on q's symbol, marks the ones met as
+// triggered and returns them with their messages. It then records q's price
+// for the rise/fall rules.
+func (b *alertBook) observe(q alertQuote) (fired []alertRule, messages []string) {
+	history := b.History[q.key()]
+	var window time.Duration
+	for i := range b.Alerts {
+		r := &b.Alerts[i]
+		if r.key() != q.key() {
+			continue
+		}
+		if r.trend() {
+			window = max(window, r.Window.Duration)
+		}
+		value, met := r.check(q, history)
+		if _, ok := q.Values[r.Field]; ok && (!r.trend() || len(history) > 0) {
+			r.Last = &value
+		}
+		if met && r.Triggered.IsZero() {
+			r.Triggered = q.At
+			fired = append(fired, *r)
+			messages = append(messages, r.message(value))
+		}
+	}
+	if window == 0 {
+		delete(b.History, q.key())
+		return fired, messages
+	}
+
+	// Keep what the longest window needs, including the last price before it
+	history = append(history, pricePoint{At: q.At, Price: q.Values["price"]})
+	since := q.At.Add(-window)
+	first := 0
+	for first+1 < len(history) && !history[first+1].At.After(since) {
+		first++
+	}
+	b.History[q.key()] = slices.Clone(history[first:])
+	return fired, messages
+}
+
+// forget drops the recorded prices no rule needs any more
+func (b *alertBook) forget() {
+	for key := range b.History {
+		if !slices.ContainsFunc(b.Alerts, func(r alertRule) bool { return r.trend() && r.key() == key }) {
+			delete(b.History, key)
+		}
+	}
+}
+
+func renderAlertsTable(table *tview.Table, alerts []alertRule) {
+	table.Clear()
+
+	headers := []string{"#", "Rule", "Status", "Last"}
+	for col, header := range headers {
+		table.SetCell(0, col,
+			tview.NewTableCell(header).
+				SetAlign(tview.AlignCenter).
+				SetSelectable(false))
+	}
+	for i, r := range alerts {
+		table.SetCell(i+1, 0, tview.NewTableCell(strconv.Itoa(i+1)).SetAlign(tview.AlignRight))
+		table.SetCell(i+1, 1, tview.NewTableCell(r.Text))
+		status := tview.NewTableCell("armed").SetTextColor(tcell.ColorGreen)
+		if !r.Triggered.IsZero() {
+			status = tview.NewTableCell("triggered " + r.Triggered.Format("2006-01-02 15:04")).SetTextColor(tcell.ColorYellow)
+		}
+		table.SetCell(i+1, 2, status)
+		last := "-"
+		if r.Last != nil && r.trend() {
+			last = fmt.Sprintf("%+.2f%%", *r.Last)
+		} else if r.Last != nil {
+			last = formatAlertValue(math.Round(*r.Last*100) / 100)
+		}
+		table.SetCell(i+1, 3, tview.NewTableCell(last).SetAlign(tview.AlignRight))
+	}
+}
+
+// runAlertCommand runs the configured command with the alert's message as its
+// last argument and the rule in its environment, logging its output under
+// "alerts".
+func runAlertCommand(command []string, r alertRule, message string, logs *logBuffer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], append(command[1:], message)...)
+	cmd.Env = append(os.Environ(),
+		"ALERT_RULE="+r.Text,
+		"ALERT_SYMBOL="+r.Symbol,
+		"ALERT_MESSAGE="+message,
+	)
+	cmd.Stdout = &lineWriter{emit: func(line string) { logs.add("alerts", line) }}
+	cmd.Stderr = &lineWriter{emit: func(line string) { logs.add("alerts", "stderr: "+line) }}
+	if err := cmd.Run(); err != nil {
+		logs.add("alerts", fmt.Sprintf("%s: %v", strings.Join(command, " "), err))
+	}
+}
+
+// TOAST
+
+// toast is a notification drawn over the top right corner of whichever page
+// is showing. It rings the terminal bell when it appears.
+type toast struct {
+	mu         sync.Mutex
+	view       *tview.TextView
+	lines      []string
+	bell       bool
+	generation int
+}
+
+func newToast() *toast {
+	view := tview.NewTextView().SetTextColor(tcell.ColorBlack)
+	view.SetBackgroundColor(tcell.ColorYellow).
+		SetBorder(true).
+		SetBorderColor(tcell.ColorBlack).
+		SetTitle(" Price alert ").
+		SetTitleColor(tcell.ColorBlack)
+	return &toast{view: view}
+}
+
+// show adds lines to the toast, which goes away toastDuration after the
+// last ones were added
+func (t *toast) show(app *tview.Application, lines ...string) {
+	t.mu.Lock()
+	t.lines = append(t.lines, lines...)
+	t.bell = true
+	t.generation++
+	generation := t.generation
+	t.mu.Unlock()
+
+	time.AfterFunc(toastDuration, func() {
+		app.QueueUpdateDraw(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if generation == t.generation {
+				t.lines = nil
+			}
+		})
+	})
+}
+
+// draw is installed as the application's after-draw function
+func (t *toast) draw(screen tcell.Screen) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.bell {
+		screen.Beep()
+		t.bell = false
+	}
+	if len(t.lines) == 0 {
+		return
+	}
+	width, _ := screen.Size()
+	lines := t.lines[max(len(t.lines)-5, 0):]
+	boxWidth := 0
+	for _, line := range lines {
+		boxWidth = max(boxWidth, tview.TaggedStringWidth(line)+4)
+	}
+	boxWidth = min(boxWidth, width-2)
+	t.view.SetText(strings.Join(lines, "\n"))
+	t.view.SetRect(width-boxWidth-1, 1, boxWidth, len(lines)+2)
+	t.view.Draw(screen)
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAlertRule(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    alertRule
+		wantErr bool
+	}{
+		{text: "AAPL close > 200", want: alertRule{Kind: alertStock, Symbol: "AAPL", Field: "close", Op: ">", Value: 200}},
+		{text: "$snow price below 1,500", want: alertRule{Kind: alertStock, Symbol: "SNOW", Field: "price", Op: "<", Value: 1500}},
+		{text: "BRK.B change% <= -2%", want: alertRule{Kind: alertStock, Symbol: "BRK.B", Field: "change%", Op: "<=", Value: -2}},
+		{text: "bitcoin price above 100000", want: alertRule{Kind: alertCrypto, Symbol: "bitcoin", Field: "price", Op: ">", Value: 100000}},
+		{text: "Bitcoin falls 5% in 24h", want: alertRule{Kind: alertCrypto, Symbol: "bitcoin", Field: "price", Op: "falls", Value: 5, Window: Duration{24 * time.Hour}}},
+		{text: "AAPL rises 3 in 7d", want: alertRule{Kind: alertStock, Symbol: "AAPL", Field: "price", Op: "rises", Value: 3, Window: Duration{7 * 24 * time.Hour}}},
+		{text: "snow close > 200", wantErr: true},
+		{text: "AAPL close > abc", wantErr: true},
+		{text: "AAPL close = 200", wantErr: true},
+		{text: "AAPL falls -5% in 1d", wantErr: true},
+		{text: "AAPL falls 5% over 1d", wantErr: true},
+		{text: "AAPL falls 5% in soon", wantErr: true},
+		{text: "$aa!pl close > 1", wantErr: true},
+		{text: "AAPL close >", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseAlertRule(test.text)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseAlertRule(%q) = %+v, want an error", test.text, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAlertRule(%q): %v", test.text, err)
+			continue
+		}
+		test.want.Text = test.text
+		if got != test.want {
+			t.Errorf("parseAlertRule(%q) = %+v, want %+v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+	}{
+		{"30m", 30 * time.Minute},
+		{"24h", 24 * time.Hour},
+		{"1h30m", 90 * time.Minute},
+		{"7d", 7 * 24 * time.Hour},
+		{"0d", 0},
+		{"-1h", 0},
+		{"d", 0},
+		{"week", 0},
+	}
+	for _, test := range tests {
+		got, err := parseWindow(test.text)
+		if test.want == 0 {
+			if err == nil {
+				t.Errorf("parseWindow(%q) = %s, want an error", test.text, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("parseWindow(%q) = %s, %v, want %s", test.text, got, err, test.want)
+		}
+	}
+}
+
+var alertStart = time.Date(2025, 5, 30, 12, 0, 0, 0, time.UTC)
+
+func pricesAt(minutes ...int) []pricePoint {
+	history := make([]pricePoint, len(minutes))
+	for i, m := range minutes {
+		history[i] = pricePoint{At: alertStart.Add(time.Duration(m) * time.Minute), Price: float64(100 + m)}
+	}
+	return history
+}
+
+func TestBaseline(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []pricePoint
+		since   int
+		want    int
+		wantOK  bool
+	}{
+		{name: "empty", since: 0},
+		{name: "last point before the window", history: pricesAt(0, 10, 20, 30), since: 15, want: 10, wantOK: true},
+		{name: "point at the start of the window", history: pricesAt(0, 10, 20, 30), since: 20, want: 20, wantOK: true},
+		{name: "all points inside the window", history: pricesAt(10, 20), since: 5, want: 10, wantOK: true},
+		{name: "all points before the window", history: pricesAt(0, 10), since: 60, want: 10, wantOK: true},
+	}
+	for _, test := range tests {
+		got, ok := baseline(test.history, alertStart.Add(time.Duration(test.since)*time.Minute))
+		if ok != test.wantOK {
+			t.Errorf("%s: ok = %v, want %v", test.name, ok, test.wantOK)
+			continue
+		}
+		if ok && got.Price != float64(100+test.want) {
+			t.Errorf("%s: baseline price %v, want %v", test.name, got.Price, 100+test.want)
+		}
+	}
+}
+
+func TestAlertCheck(t *testing.T) {
+	quote := func(price float64) alertQuote {
+		return alertQuote{Kind: alertCrypto, Symbol: "bitcoin", Values: map[string]float64{"price": price}, At: alertStart.Add(time.Hour)}
+	}
+	rule := func(op string, value float64) alertRule {
+		return alertRule{Kind: alertCrypto, Symbol: "bitcoin", Field: "price", Op: op, Value: value, Window: Duration{time.Hour}}
+	}
+	history := []pricePoint{{At: alertStart, Price: 100}}
+	tests := []struct {
+		name      string
+		rule      alertRule
+		price     float64
+		history   []pricePoint
+		wantValue float64
+		wantMet   bool
+	}{
+		{name: "above", rule: rule(">", 100), price: 101, wantValue: 101, wantMet: true},
+		{name: "not strictly above", rule: rule(">", 100), price: 100, wantValue: 100},
+		{name: "at least", rule: rule(">=", 100), price: 100, wantValue: 100, wantMet: true},
+		{name: "below", rule: rule("<", 100), price: 99, wantValue: 99, wantMet: true},
+		{name: "at most", rule: rule("<=", 100), price: 101, wantValue: 101},
+		{name: "rises enough", rule: rule("rises", 5), price: 105, history: history, wantValue: 5, wantMet: true},
+		{name: "rises too little", rule: rule("rises", 5), price: 104, history: history, wantValue: 4},
+		{name: "falls enough", rule: rule("falls", 5), price: 95, history: history, wantValue: -5, wantMet: true},
+		{name: "falls on a rise", rule: rule("falls", 5), price: 110, history: history, wantValue: 10},
+		{name: "rises on a fall", rule: rule("rises", 5), price: 90, history: history, wantValue: -10},
+		{name: "trend without history", rule: rule("rises", 5), price: 200},
+	}
+	for _, test := range tests {
+		value, met := test.rule.check(quote(test.price), test.history)
+		if met != test.wantMet || !closeTo(value, test.wantValue) {
+			t.Errorf("%s: check = %v, %v, want %v, %v", test.name, value, met, test.wantValue, test.wantMet)
+		}
+	}
+}
+
+func TestObserveFiresOnceUntilRearmed(t *testing.T) {
+	book := &alertBook{History: make(map[string][]pricePoint)}
+	rule, err := parseAlertRule("AAPL close > 200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	book.Alerts = []alertRule{rule}
+	quote := func(close float64, minutes int) alertQuote {
+		return stockAlertQuote(StockData{Ticker: "aapl", Open: close, Close: close}, alertStart.Add(time.Duration(minutes)*time.Minute))
+	}
+
+	if fired, _ := book.observe(quote(199, 0)); len(fired) != 0 {
+		t.Fatalf("fired below the threshold: %+v", fired)
+	}
+	fired, messages := book.observe(quote(201, 1))
+	if len(fired) != 1 || messages[0] != "AAPL close is 201 (close > 200)" {
+		t.Fatalf("observe = %+v, %q, want one alert", fired, messages)
+	}
+	if !book.Alerts[0].Triggered.Equal(alertStart.Add(time.Minute)) {
+		t.Errorf("triggered at %s", book.Alerts[0].Triggered)
+	}
+	if fired, _ := book.observe(quote(202, 2)); len(fired) != 0 {
+		t.Errorf("fired again while triggered")
+	}
+	if last := book.Alerts[0].Last; last == nil || *last != 202 {
+		t.Errorf("last = %v, want 202", last)
+	}
+	if len(book.History) != 0 {
+		t.Errorf("kept history without a rise/fall rule: %v", book.History)
+	}
+
+	book.Alerts[0].Triggered = time.Time{}
+	if fired, _ := book.observe(quote(203, 3)); len(fired) != 1 {
+		t.Errorf("did not fire after being re-armed")
+	}
+	other := stockAlertQuote(StockData{Ticker: "MSFT", Close: 500}, alertStart.Add(4*time.Minute))
+	book.Alerts[0].Triggered = time.Time{}
+	if fired, _ := book.observe(other); len(fired) != 0 {
+		t.Errorf("fired on another symbol's quote")
+	}
+}
+
+func TestObserveTrendHistory(t *testing.T) {
+	rule, err := parseAlertRule("bitcoin falls 5% in 30m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := &alertBook{Alerts: []alertRule{rule}, History: make(map[string][]pricePoint)}
+	observe := func(price float64, minutes int) []alertRule {
+		fired, _ := book.observe(alertQuote{Kind: alertCrypto, Symbol: "bitcoin", Values: map[string]float64{"price": price}, At: alertStart.Add(time.Duration(minutes) * time.Minute)})
+		return fired
+	}
+
+	for i, price := range []float64{100, 99, 98, 97} {
+		if fired := observe(price, i*20); len(fired) != 0 {
+			t.Fatalf("fired at %v", price)
+		}
+	}
+	// At minute 60 the window starts at 30: the price seen at 20 is its
+	// baseline, and everything before it is dropped
+	history := book.History["crypto:bitcoin"]
+	if len(history) != 3 || history[0].Price != 99 {
+		t.Errorf("history = %v, want it to start at the price seen at minute 20", history)
+	}
+	// At minute 61 the baseline is still the 99 seen at minute 20, so 95 is
+	// not a 5% fall from it, though it is from 100, but 93 is
+	if fired := observe(95, 61); len(fired) != 0 {
+		t.Errorf("fired on a fall measured from the wrong baseline")
+	}
+	if fired := observe(93, 62); len(fired) != 1 {
+		t.Errorf("did not fire on a 5%% fall within the window")
+	}
+
+	book.Alerts = nil
+	book.forget()
+	if len(book.History) != 0 {
+		t.Errorf("forget kept %v", book.History)
+	}
+}
+
+func closeTo(got, want float64) bool {
+	return got-want < 1e-9 && want-got < 1e-9
+}
+
+func TestSameAlert(t *testing.T) {
+	rule, err := parseAlertRule("AAPL close > 200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := rule
+	last := 201.0
+	checked.Triggered, checked.Last = alertStart, &last
+	if !checked.sameAlert(rule) {
+		t.Errorf("a triggered rule is not the same alert")
+	}
+	other, err := parseAlertRule("AAPL close > 210")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.sameAlert(rule) {
+		t.Errorf("rules with other values are the same alert")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// QUOTE CACHE
+// Responses of the stock, summary and crypto services are kept in quotes.json
+// next to the config file, keyed by the request without its request_id: the
+// ticker, coin, index set or date range asked for. A response younger than
+// its contract's TTL is served without asking the service again; an older one
+// is shown instead when the service cannot answer. Refreshes, such as the
+// summary's and the watchlist's, always ask the service and only fall back on
+// the cache.
+
+// cachedContracts are the contracts whose responses are cached. Budget
+// results never are.
+var cachedContracts = []string{serviceSummary, serviceSummary + ".history", serviceStock, serviceStock + ".history", serviceCrypto}
+
+// defaultCacheTTL is how long a response stays fresh, by contract. The
+// summary is only ever refreshed, so it has no TTL.
+var defaultCacheTTL = map[string]time.Duration{
+	serviceSummary + ".history": 15 * time.Minute,
+	serviceStock:                30 * time.Second,
+	serviceStock + ".history":   15 * time.Minute,
+	serviceCrypto:               30 * time.Second,
+}
+
+// cacheRetention is how long a response is kept to fall back on
+const cacheRetention = 7 * 24 * time.Hour
+
+type cacheEntry struct {
+	At       time.Time       `json:"at"`
+	Response json.RawMessage `json:"response"`
+}
+
+type quoteCache struct {
+	mu      sync.Mutex
+	path    string
+	ttl     map[string]time.Duration
+	entries map[string]cacheEntry
+	logs    *logBuffer
+}
+
+// loadQuoteCache reads the cache at path, dropping responses older than
+// cacheRetention. A cache that cannot be read is discarded, since it only
+// saves requests. Contracts without a TTL always ask the service but keep
+// their responses to fall back on. Problems with the file are logged under
+// "cache".
+func loadQuoteCache(path string, ttl map[string]time.Duration, logs *logBuffer) *quoteCache {
+	c := &quoteCache{path: path, ttl: ttl, entries: make(map[string]cacheEntry), logs: logs}
+	if _, err := loadState(path, &c.entries); err != nil {
+		logs.add("cache", fmt.Sprintf("discarding the quote cache: %v", err))
+		c.entries = make(map[string]cacheEntry)
+		if err := os.Remove(path); err != nil {
+			logs.add("cache", err.Error())
+		}
+	}
+	c.prune(time.Now())
+	return c
+}
+
+func (c *quoteCache) caches(contract string) bool {
+	return c != nil && slices.Contains(cachedContracts, contract)
+}
+
+// lookup returns the cached response to key and whether it is still fresh
+func (c *quoteCache) lookup(contract, key string, now time.Time) (entry cacheEntry, fresh, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok = c.entries[key]
+	return entry, ok && now.Sub(entry.At) < c.ttl[contract], ok
+}
+
+func (c *quoteCache) store(key string, response []byte, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = cacheEntry{At: at, Response: bytes.Clone(response)}
+	c.prune(at)
+	if err := saveState(c.path, c.entries); err != nil {
+		c.logs.add("cache", fmt.Sprintf("could not save %s: %v", c.path, err))
+	}
+}
+
+func (c *quoteCache) prune(now time.Time) {
+	for key, entry := range c.entries {
+		if now.Sub(entry.At) > cacheRetention {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// quoteAge tells where a response came from. The zero value is a response the
+// service has just sent.
+type quoteAge struct {
+	Cached bool
+	At     time.Time
+	// Err is why the service could not answer when an expired response is
+	// shown instead
+	Err error
+}
+
+// label describes a cached response for the page showing it, or is empty for
+// a fresh one. Pages show Err themselves.
+func (a quoteAge) label(now time.Time) string {
+	switch {
+	case !a.Cached:
+		return ""
+	case a.Err != nil:
+		return fmt.Sprintf("offline, data from %s ago", formatCountdown(now.Sub(a.At)))
+	}
+	return fmt.Sprintf("cached %s ago", formatCountdown(now.Sub(a.At)))
+}
+
+// titled adds the label, if any, to a chart title
+func (a quoteAge) titled(title string, now time.Time) string {
+	if label := a.label(now); label != "" {
+		return fmt.Sprintf("%s (%s)", title, label)
+	}
+	return title
+}
+
+// cachePolicy is how a request uses the quote cache
+type cachePolicy int
+
+const (
+	// cacheFirst answers from a fresh cached response without asking the
+	// service
+	cacheFirst cachePolicy = iota
+	// cacheFallback always asks the service, as refreshes must
+	cacheFallback
+)
+
+// fetchCached is fetchAs through the service's quote cache. With cacheFirst, a
+// fresh cached response is handed to onLoaded without sending a request. When
+// the call fails for any reason but a service error, such as an unknown
+// ticker, the last cached response is handed over instead, with the error in
+// its quoteAge.
+func fetchCached[T any](ctx context.Context, app *tview.Application, svc *Service, contract string, policy cachePolicy, build func(id string) ([]byte, error), decode func([]byte) (T, error), onLoaded func(T, quoteAge), onError func(error), onStale func(error)) {
+	loaded := func(result T) { onLoaded(result, quoteAge{}) }
+	cache := svc.Cache
+	if !cache.caches(contract) {
+		fetchAs(ctx, app, svc, contract, build, decode, loaded, onError, onStale)
+		return
+	}
+	body, err := build("")
+	if err != nil {
+		onError(err)
+		return
+	}
+	key := contract + " " + requestKey(body)
+
+	entry, fresh, cached := cache.lookup(contract, key, time.Now())
+	if fresh && policy == cacheFirst {
+		if result, err := decode(entry.Response); err == nil {
+			go app.QueueUpdateDraw(func() {
+				if ctx.Err() == nil {
+					onLoaded(result, quoteAge{Cached: true, At: entry.At})
+				}
+			})
+			return
+		}
+	}
+
+	decodeAndStore := func(data []byte) (T, error) {
+		result, err := decode(data)
+		if err == nil {
+			cache.store(key, data, time.Now())
+		}
+		return result, err
+	}
+	fallback := func(err error) {
+		var serviceErr *ServiceError
+		if cached && !errors.As(err, &serviceErr) {
+			if result, decodeErr := decode(entry.Response); decodeErr == nil {
+				onLoaded(result, quoteAge{Cached: true, At: entry.At, Err: err})
+				return
+			}
+		}
+		onError(err)
+	}
+	fetchAs(ctx, app, svc, contract, build, decodeAndStore, loaded, fallback, onStale)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	_ "time/tzdata"
+)
+
+// EXCHANGE CALENDAR
+// NYSE and NASDAQ keep the same sessions and holidays. Holidays follow the
+// NYSE rules and are worked out for any year; one-off closures are listed in
+// specialClosures.
+
+type session int
+
+const (
+	sessionClosed session = iota
+	sessionPreMarket
+	sessionRegular
+	sessionAfterHours
+)
+
+func (s session) String() string {
+	switch s {
+	case sessionPreMarket:
+		return "pre-market"
+	case sessionRegular:
+		return "regular"
+	case sessionAfterHours:
+		return "after-hours"
+	}
+	return "closed"
+}
+
+// exchangeCalendar holds the trading hours of an exchange in its local time.
+// On early-close days the regular session ends at EarlyClose and after-hours
+// trading ends as much earlier.
+type exchangeCalendar struct {
+	Name       string
+	Location   *time.Location
+	PreOpen    time.Duration
+	Open       time.Duration
+	Close      time.Duration
+	EarlyClose time.Duration
+	AfterClose time.Duration
+}
+
+var usMarket = &exchangeCalendar{
+	Name:       "NYSE",
+	Location:   mustLoadLocation("America/New_York"),
+	PreOpen:    4 * time.Hour,
+	Open:       9*time.Hour + 30*time.Minute,
+	Close:      16 * time.Hour,
+	EarlyClose: 13 * time.Hour,
+	AfterClose: 20 * time.Hour,
+}
+
+var specialClosures = map[string]string{
+	"2012-10-29": "Hurricane Sandy",
+	"2012-10-30": "Hurricane Sandy",
+	"2018-12-05": "Day of Mourning for George H.W. Bush",
+	"2025-01-09": "Day of Mourning for Jimmy Carter",
+}
+
+func mustLoadLocation(name string) *time.Location {
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		panic(err)
+	}
+	return location
+}
+
+// day is midnight of t's date in the exchange's time zone
+func (c *exchangeCalendar) day(t time.Time) time.Time {
+	t = t.In(c.Location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, c.Location)
+}
+
+// holiday reports the name of the holiday the exchange is closed for on
+// day, if any. Weekends are not holidays.
+func (c *exchangeCalendar) holiday(day time.Time) (string, bool) {
+	day = c.day(day)
+	if name, ok := specialClosures[day.Format(dateLayout)]; ok {
+		return name, true
+	}
+	year := day.Year()
+
+	holidays := []struct {
+		name string
+		date time.Time
+	}{
+		{"New Year's Day", observed(time.Date(year, time.January, 1, 0, 0, 0, 0, c.Location))},
+		{"Martin Luther King Jr. Day", nthWeekday(year, time.January, time.Monday, 3, c.Location)},
+		{"Washington's Birthday", nthWeekday(year, time.February, time.Monday, 3, c.Location)},
+		{"Good Friday", easter(year, c.Location).AddDate(0, 0, -2)},
+		{"Memorial Day", lastWeekday(year, time.May, time.Monday, c.Location)},
+		{"Independence Day", observed(time.Date(year, time.July, 4, 0, 0, 0, 0, c.Location))},
+		{"Labor Day", nthWeekday(year, time.September, time.Monday, 1, c.Location)},
+		{"Thanksgiving Day", nthWeekday(year, time.November, time.Thursday, 4, c.Location)},
+		{"Christmas Day", observed(time.Date(year, time.December, 25, 0, 0, 0, 0, c.Location))},
+	}
+	if year >= 2022 {
+		holidays = append(holidays, struct {
+			name string
+			date time.Time
+		}{"Juneteenth", observed(time.Date(year, time.June, 19, 0, 0, 0, 0, c.Location))})
+	}
+	for _, h := range holidays {
+		if h.date.Equal(day) {
+			return h.name, true
+		}
+	}
+	return "", false
+}
+
+// earlyClose reports whether the regular session ends early on day: the day
+// before Independence Day, the day after Thanksgiving and Christmas Eve.
+func (c *exchangeCalendar) earlyClose(day time.Time) bool {
+	day = c.day(day)
+	if !c.isTradingDay(day) {
+		return false
+	}
+	year := day.Year()
+	switch {
+	case day.Month() == time.July && day.Day() == 3:
+		return true
+	case day.Equal(nthWeekday(year, time.November, time.Thursday, 4, c.Location).AddDate(0, 0, 1)):
+		return true
+	case day.Month() == time.December && day.Day() == 24:
+		return true
+	}
+	return false
+}
+
+func (c *exchangeCalendar) isTradingDay(day time.Time) bool {
+	day = c.day(day)
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	_, closed := c.holiday(day)
+	return !closed
+}
+
+// hours returns when pre-market, regular and after-hours trading start and
+// when after-hours trading ends on a trading day.
+func (c *exchangeCalendar) hours(day time.Time) (preOpen, open, close, afterClose time.Time) {
+	day = c.day(day)
+	at := func(d time.Duration) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, c.Location)
+	}
+	closing, after := c.Close, c.AfterClose
+	if c.earlyClose(day) {
+		closing, after = c.EarlyClose, c.EarlyClose+(c.AfterClose-c.Close)
+	}
+	return at(c.PreOpen), at(c.Open), at(closing), at(after)
+}
+
+func (c *exchangeCalendar) session(t time.Time) session {
+	if !c.isTradingDay(t) {
+		return sessionClosed
+	}
+	preOpen, open, close, afterClose := c.hours(t)
+	switch {
+	case t.Before(preOpen):
+		return sessionClosed
+	case t.Before(open):
+		return sessionPreMarket
+	case t.Before(close):
+		return sessionRegular
+	case t.Before(afterClose):
+		return sessionAfterHours
+	}
+	return sessionClosed
+}
+
+// nextOpen is the start of the next regular session after t
+func (c *exchangeCalendar) nextOpen(t time.Time) time.Time {
+	for day := c.day(t); ; day = day.AddDate(0, 0, 1) {
+		if !c.isTradingDay(day) {
+			continue
+		}
+		if _, open, _, _ := c.hours(day); open.After(t) {
+			return open
+		}
+	}
+}
+
+// nextClose is the end of the regular session in progress at t, or of the
+// next one.
+func (c *exchangeCalendar) nextClose(t time.Time) time.Time {
+	for day := c.day(t); ; day = day.AddDate(0, 0, 1) {
+		if !c.isTradingDay(day) {
+			continue
+		}
+		if _, _, close, _ := c.hours(day); close.After(t) {
+			return close
+		}
+	}
+}
+
+// quoteSession labels a quote dated date: the session under way if the
+// quote is from today's trading day, otherwise closed.
+func (c *exchangeCalendar) quoteSession(date string, now time.Time) session {
+	if date != c.day(now).Format(dateLayout) {
+		return sessionClosed
+	}
+	return c.session(now)
+}
+
+// observed moves a holiday on a Sunday to Monday and one on a Saturday to
+// Friday, except that a Saturday New Year's Day is not made up.
+func observed(date time.Time) time.Time {
+	switch date.Weekday() {
+	case time.Sunday:
+		return date.AddDate(0, 0, 1)
+	case time.Saturday:
+		if date.Month() == time.January && date.Day() == 1 {
+			return time.Time{}
+		}
+		return date.AddDate(0, 0, -1)
+	}
+	return date
+}
+
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int, location *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, location)
+	offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7*(n-1))
+}
+
+func lastWeekday(year int, month time.Month, weekday time.Weekday, location *time.Location) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, location)
+	offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+	return last.AddDate(0, 0, -offset)
+}
+
+// easter is Easter Sunday by the anonymous Gregorian algorithm
+func easter(year int, location *time.Location) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, location)
+}
+
+// MARKET CLOCK
+
+// marketClock ticks once a second so every page header can show the
+// exchange's session and a countdown to its next open or close. Listeners
+// are called from the clock's goroutine and must not block.
+type marketClock struct {
+	calendar  *exchangeCalendar
+	mu        sync.Mutex
+	listeners []func(now time.Time)
+}
+
+func newMarketClock(calendar *exchangeCalendar) *marketClock {
+	return &marketClock{calendar: calendar}
+}
+
+func (c *marketClock) subscribe(listener func(now time.Time)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, listener)
+}
+
+func (c *marketClock) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			c.mu.Lock()
+			listeners := append([]func(time.Time){}, c.listeners...)
+			c.mu.Unlock()
+			for _, listener := range listeners {
+				listener(now)
+			}
+		}
+	}
+}
+
+func renderMarketIndicator(calendar *exchangeCalendar, now time.Time) string {
+	current := calendar.session(now)
+	switch current {
+	case sessionRegular:
+		return fmt.Sprintf("[green]●[-] %s open, closes in %s", calendar.Name, formatCountdown(calendar.nextClose(now).Sub(now)))
+	case sessionPreMarket, sessionAfterHours:
+		return fmt.Sprintf("[yellow]●[-] %s %s, opens in %s", calendar.Name, current, formatCountdown(calendar.nextOpen(now).Sub(now)))
+	}
+	reason := "closed"
+	if name, ok := calendar.holiday(now); ok {
+		reason = "closed for " + name
+	}
+	return fmt.Sprintf("[gray]●[-] %s %s, opens in %s", calendar.Name, reason, formatCountdown(calendar.nextOpen(now).Sub(now)))
+}
+
+func formatCountdown(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %02dm", d/time.Hour, d%time.Hour/time.Minute)
+	}
+	return fmt.Sprintf("%dm %02ds", d/time.Minute, d%time.Minute/time.Second)
+}
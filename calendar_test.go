@@ -0,0 +1,118 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTradingDays(t *testing.T) {
+	tests := []struct {
+		date    string
+		holiday string
+		trading bool
+		early   bool
+	}{
+		{date: "2024-03-29", holiday: "Good Friday"},
+		{date: "2025-04-18", holiday: "Good Friday"},
+		{date: "2026-04-03", holiday: "Good Friday"},
+		{date: "2025-04-21", trading: true},
+
+		// Saturday holidays are observed on Friday, except New Year's Day
+		{date: "2020-07-03", holiday: "Independence Day"},
+		{date: "2020-07-02", trading: true},
+		{date: "2021-12-24", holiday: "Christmas Day"},
+		{date: "2021-12-31", trading: true},
+		{date: "2021-06-18", trading: true},
+		// Sunday holidays are observed on Monday
+		{date: "2022-06-20", holiday: "Juneteenth"},
+		{date: "2022-12-26", holiday: "Christmas Day"},
+		{date: "2023-01-02", holiday: "New Year's Day"},
+
+		{date: "2024-11-28", holiday: "Thanksgiving Day"},
+		{date: "2024-11-29", trading: true, early: true},
+		{date: "2025-11-28", trading: true, early: true},
+		{date: "2023-07-03", trading: true, early: true},
+		{date: "2024-07-03", trading: true, early: true},
+		{date: "2024-12-24", trading: true, early: true},
+		{date: "2024-12-23", trading: true},
+		{date: "2023-12-24"},
+
+		{date: "2025-01-09", holiday: "Day of Mourning for Jimmy Carter"},
+		{date: "2025-05-31"},
+	}
+	for _, test := range tests {
+		day, err := time.ParseInLocation(dateLayout, test.date, usMarket.Location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name, _ := usMarket.holiday(day); name != test.holiday {
+			t.Errorf("%s: holiday %q, want %q", test.date, name, test.holiday)
+		}
+		if trading := usMarket.isTradingDay(day); trading != test.trading {
+			t.Errorf("%s: trading day %v, want %v", test.date, trading, test.trading)
+		}
+		if early := usMarket.earlyClose(day); early != test.early {
+			t.Errorf("%s: early close %v, want %v", test.date, early, test.early)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	tests := []struct {
+		at   string
+		want session
+	}{
+		// New York is UTC-5 until the second Sunday of March, UTC-4 after
+		{at: "2025-03-07T13:45:00Z", want: sessionPreMarket},
+		{at: "2025-03-07T14:45:00Z", want: sessionRegular},
+		{at: "2025-03-10T13:45:00Z", want: sessionRegular},
+		{at: "2025-03-10T20:15:00Z", want: sessionAfterHours},
+		// and again from the first Sunday of November
+		{at: "2025-10-31T13:45:00Z", want: sessionRegular},
+		{at: "2025-10-31T20:15:00Z", want: sessionAfterHours},
+		{at: "2025-11-03T13:45:00Z", want: sessionPreMarket},
+		{at: "2025-11-03T20:15:00Z", want: sessionRegular},
+
+		{at: "2024-11-29T17:59:00Z", want: sessionRegular},
+		{at: "2024-11-29T18:00:00Z", want: sessionAfterHours},
+		{at: "2024-11-29T22:00:00Z", want: sessionClosed},
+		{at: "2024-12-02T08:59:00Z", want: sessionClosed},
+		{at: "2024-12-02T09:00:00Z", want: sessionPreMarket},
+		{at: "2025-04-18T15:00:00Z", want: sessionClosed},
+	}
+	for _, test := range tests {
+		at, err := time.Parse(time.RFC3339, test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := usMarket.session(at); got != test.want {
+			t.Errorf("session(%s) = %s, want %s", test.at, got, test.want)
+		}
+	}
+}
+
+func TestNextOpenAndClose(t *testing.T) {
+	tests := []struct {
+		at        string
+		wantOpen  string
+		wantClose string
+	}{
+		{at: "2025-03-07T22:00:00Z", wantOpen: "2025-03-10T13:30:00Z", wantClose: "2025-03-10T20:00:00Z"},
+		{at: "2025-10-31T22:00:00Z", wantOpen: "2025-11-03T14:30:00Z", wantClose: "2025-11-03T21:00:00Z"},
+		{at: "2025-04-17T21:00:00Z", wantOpen: "2025-04-21T13:30:00Z", wantClose: "2025-04-21T20:00:00Z"},
+		{at: "2024-11-29T15:00:00Z", wantOpen: "2024-12-02T14:30:00Z", wantClose: "2024-11-29T18:00:00Z"},
+		{at: "2021-12-23T22:00:00Z", wantOpen: "2021-12-27T14:30:00Z", wantClose: "2021-12-27T21:00:00Z"},
+	}
+	for _, test := range tests {
+		at, err := time.Parse(time.RFC3339, test.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := usMarket.nextOpen(at).UTC().Format(time.RFC3339); got != test.wantOpen {
+			t.Errorf("nextOpen(%s) = %s, want %s", test.at, got, test.wantOpen)
+		}
+		if got := usMarket.nextClose(at).UTC().Format(time.RFC3339); got != test.wantClose {
+			t.Errorf("nextClose(%s) = %s, want %s", test.at, got, test.wantClose)
+		}
+	}
+}
@@ -0,0 +1,699 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattjmelnick/cs361-main/indicators"
+	"github.com/rivo/tview"
+)
+
+// CHARTS
+
+type chartPoint struct {
+	Label string
+	Value float64
+}
+
+// chartSeries is one line of a chart. All series of a chart have a point for
+// the same labels.
+type chartSeries struct {
+	Name   string
+	Color  tcell.Color
+	Points []chartPoint
+}
+
+// lineChart draws series as lines of braille dots. Every cell holds a 2x4
+// grid of dots, so a line has twice the horizontal and four times the
+// vertical resolution of the text grid. The left and right arrow keys (or a
+// mouse click) move a cursor whose point is read out above the chart.
+type lineChart struct {
+	*tview.Box
+	series  []chartSeries
+	percent bool
+	cursor  int
+	message string
+
+	// plot is where the last Draw put the dots, for the cursor keys and mouse
+	plotX, plotWidth int
+}
+
+func newLineChart() *lineChart {
+	return &lineChart{Box: tview.NewBox()}
+}
+
+// SetSeries shows a single series of prices, green if it ended higher than
+// it started and red otherwise, and puts the cursor on the latest point.
+func (c *lineChart) SetSeries(title string, points []chartPoint) *lineChart {
+	color := tcell.ColorGreen
+	if len(points) > 0 && points[len(points)-1].Value < points[0].Value {
+		color = tcell.ColorRed
+	}
+	return c.setSeries([]chartSeries{{Name: title, Color: color, Points: points}}, false)
+}
+
+// SetComparison shows several series of percentage changes
+func (c *lineChart) SetComparison(series []chartSeries) *lineChart {
+	return c.setSeries(series, true)
+}
+
+func (c *lineChart) setSeries(series []chartSeries, percent bool) *lineChart {
+	c.series = series
+	c.percent = percent
+	c.message = ""
+	if c.length() == 0 {
+		names := make([]string, len(series))
+		for i, s := range series {
+			names[i] = s.Name
+		}
+		c.series = nil
+		c.message = "No data for " + strings.Join(names, ", ")
+	}
+	c.cursor = c.length() - 1
+	return c
+}
+
+// SetMessage clears the chart and shows text in its place
+func (c *lineChart) SetMessage(text string) *lineChart {
+	c.series = nil
+	c.message = text
+	return c
+}
+
+// length is the number of points along the chart
+func (c *lineChart) length() int {
+	if len(c.series) == 0 {
+		return 0
+	}
+	return len(c.series[0].Points)
+}
+
+func (c *lineChart) formatValue(v float64) string {
+	if c.percent {
+		return fmt.Sprintf("%+.2f%%", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// readout describes the points under the cursor
+func (c *lineChart) readout() string {
+	first := c.series[0].Points[0]
+	point := c.series[0].Points[c.cursor]
+	if !c.percent {
+		change := point.Value - first.Value
+		return fmt.Sprintf("%s  %s  %.2f  %+.2f (%+.2f%%) since %s",
+			c.series[0].Name, point.Label, point.Value, change, change/first.Value*100, first.Label)
+	}
+	text := point.Label + " "
+	for _, s := range c.series {
+		text += fmt.Sprintf(" [%s]%s %s[-]", s.Color, s.Name, c.formatValue(s.Points[c.cursor].Value))
+	}
+	return text + "  since " + first.Label
+}
+
+func (c *lineChart) Draw(screen tcell.Screen) {
+	c.Box.DrawForSubclass(screen, c)
+	x, y, width, height := c.GetInnerRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	n := c.length()
+	if n == 0 {
+		tview.Print(screen, tview.Escape(c.message), x, y, width, tview.AlignLeft, tview.Styles.SecondaryTextColor)
+		return
+	}
+
+	low, high := c.series[0].Points[0].Value, c.series[0].Points[0].Value
+	for _, s := range c.series {
+		for _, p := range s.Points {
+			low = math.Min(low, p.Value)
+			high = math.Max(high, p.Value)
+		}
+	}
+	tview.Print(screen, c.readout(), x, y, width, tview.AlignLeft, tview.Styles.PrimaryTextColor)
+
+	// Value axis on the left, date axis along the bottom
+	highLabel, lowLabel := c.formatValue(high), c.formatValue(low)
+	axisWidth := max(len(highLabel), len(lowLabel)) + 1
+	rows := height - 2
+	c.plotX, c.plotWidth = x+axisWidth, width-axisWidth
+	if rows < 1 || c.plotWidth < 2 {
+		return
+	}
+	labels := c.series[0].Points
+	tview.Print(screen, highLabel, x, y+1, axisWidth-1, tview.AlignRight, tview.Styles.SecondaryTextColor)
+	tview.Print(screen, lowLabel, x, y+rows, axisWidth-1, tview.AlignRight, tview.Styles.SecondaryTextColor)
+	tview.Print(screen, labels[0].Label, c.plotX, y+rows+1, c.plotWidth, tview.AlignLeft, tview.Styles.SecondaryTextColor)
+	tview.Print(screen, labels[n-1].Label, c.plotX, y+rows+1, c.plotWidth, tview.AlignRight, tview.Styles.SecondaryTextColor)
+
+	dotsX, dotsY := c.plotWidth*2, rows*4
+	dotX := func(i int) int {
+		if n == 1 {
+			return 0
+		}
+		return i * (dotsX - 1) / (n - 1)
+	}
+	dotY := func(v float64) int {
+		if high == low {
+			return dotsY / 2
+		}
+		return int(math.Round((high - v) / (high - low) * float64(dotsY-1)))
+	}
+
+	// Where lines cross, the cell takes the color of the last series drawn
+	cells := make([][]rune, rows)
+	colors := make([][]tcell.Color, rows)
+	for row := range cells {
+		cells[row] = make([]rune, c.plotWidth)
+		colors[row] = make([]tcell.Color, c.plotWidth)
+	}
+	for _, s := range c.series {
+		setDot := func(dx, dy int) {
+			cells[dy/4][dx/2] |= brailleDots[dx%2][dy%4]
+			colors[dy/4][dx/2] = s.Color
+		}
+		for i := range s.Points {
+			x1, y1 := dotX(i), dotY(s.Points[i].Value)
+			if i == 0 {
+				setDot(x1, y1)
+				continue
+			}
+			drawLine(dotX(i-1), dotY(s.Points[i-1].Value), x1, y1, setDot)
+		}
+	}
+
+	cursorCol := dotX(c.cursor) / 2
+	for row := range cells {
+		for col, dots := range cells[row] {
+			style := tcell.StyleDefault.Foreground(colors[row][col])
+			if col == cursorCol {
+				style = style.Background(tcell.ColorDarkSlateGray)
+			}
+			screen.SetContent(c.plotX+col, y+1+row, 0x2800+dots, nil, style)
+		}
+	}
+}
+
+// brailleDots[x][y] is the bit of dot (x, y) in a braille character
+var brailleDots = [2][4]rune{
+	{0x01, 0x02, 0x04, 0x40},
+	{0x08, 0x10, 0x20, 0x80},
+}
+
+// drawLine calls set for every dot on the line from (x0, y0) to (x1, y1)
+func drawLine(x0, y0, x1, y1 int, set func(x, y int)) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	err := dx + dy
+	for {
+		set(x0, y0)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	}
+	return 0
+}
+
+// moveCursor moves the cursor by delta dot columns, clamped to the series
+func (c *lineChart) moveCursor(delta int) {
+	n := c.length()
+	if n == 0 {
+		return
+	}
+	step := 1
+	if dots := c.plotWidth * 2; dots > 0 && n > dots {
+		step = (n + dots - 1) / dots
+	}
+	c.cursor = min(max(c.cursor+delta*step, 0), n-1)
+}
+
+func (c *lineChart) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return c.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		switch event.Key() {
+		case tcell.KeyLeft:
+			c.moveCursor(-1)
+		case tcell.KeyRight:
+			c.moveCursor(1)
+		case tcell.KeyHome:
+			c.cursor = 0
+		case tcell.KeyEnd:
+			c.cursor = max(c.length()-1, 0)
+		}
+	})
+}
+
+func (c *lineChart) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return c.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if action != tview.MouseLeftClick || !c.InRect(event.Position()) {
+			return false, nil
+		}
+		setFocus(c)
+		mx, _ := event.Position()
+		if n := c.length(); n > 1 && c.plotWidth > 0 && mx >= c.plotX {
+			dot := (mx - c.plotX) * 2
+			c.cursor = min(dot*(n-1)/(c.plotWidth*2-1), n-1)
+		}
+		return true, nil
+	})
+}
+
+// candleChart draws price bars as candlesticks above a strip of volume bars.
+// Each candle takes one column: a thin line from low to high with a thick
+// body between open and close, green when the close is at or above the open
+// and red below it. The arrow keys move a cursor whose bar is read out above
+// the chart; +/- zoom in and out around the cursor and PgUp/PgDn pan by a
+// screen. The keys of candleIndicators switch indicators on and off.
+type candleChart struct {
+	*tview.Box
+	title   string
+	bars    []StockBar
+	cursor  int
+	first   int // index of the leftmost visible bar
+	span    int // number of visible bars; 0 fits as many as there is room for
+	message string
+
+	values   map[rune][][]float64 // every indicator's series for bars
+	overlays map[rune]bool        // overlays switched on
+	lower    rune                 // indicator shown instead of volume, if any
+
+	// where the last Draw put the candles, for the mouse
+	plotX, plotWidth int
+}
+
+func newCandleChart() *candleChart {
+	return &candleChart{Box: tview.NewBox(), overlays: make(map[rune]bool)}
+}
+
+// candleIndicator is a technical indicator the candle chart can show,
+// switched on and off by Key. Overlays share the candles' price scale; the
+// others take the place of the volume strip, one at a time.
+type candleIndicator struct {
+	Key     rune
+	Name    string
+	Overlay bool
+	Colors  []tcell.Color // one per series
+	Compute func(bars []StockBar) [][]float64
+}
+
+var candleIndicators = []candleIndicator{
+	{Key: 's', Name: "SMA 20", Overlay: true, Colors: []tcell.Color{tcell.ColorYellow}, Compute: func(bars []StockBar) [][]float64 {
+		return [][]float64{indicators.SMA(barValues(bars, closeOf), 20)}
+	}},
+	{Key: 'e', Name: "EMA 20", Overlay: true, Colors: []tcell.Color{tcell.ColorAqua}, Compute: func(bars []StockBar) [][]float64 {
+		return [][]float64{indicators.EMA(barValues(bars, closeOf), 20)}
+	}},
+	{Key: 'b', Name: "BB 20,2", Overlay: true, Colors: []tcell.Color{tcell.ColorFuchsia, tcell.ColorFuchsia, tcell.ColorFuchsia}, Compute: func(bars []StockBar) [][]float64 {
+		middle, upper, lower := indicators.Bollinger(barValues(bars, closeOf), 20, 2)
+		return [][]float64{middle, upper, lower}
+	}},
+	{Key: 'r', Name: "RSI 14", Colors: []tcell.Color{tcell.ColorOrange}, Compute: func(bars []StockBar) [][]float64 {
+		return [][]float64{indicators.RSI(barValues(bars, closeOf), 14)}
+	}},
+	{Key: 'm', Name: "MACD 12,26,9", Colors: []tcell.Color{tcell.ColorAqua, tcell.ColorYellow}, Compute: func(bars []StockBar) [][]float64 {
+		macd, signal, _ := indicators.MACD(barValues(bars, closeOf), 12, 26, 9)
+		return [][]float64{macd, signal}
+	}},
+	{Key: 'a', Name: "ATR 14", Colors: []tcell.Color{tcell.ColorOrange}, Compute: func(bars []StockBar) [][]float64 {
+		return [][]float64{indicators.ATR(barValues(bars, highOf), barValues(bars, lowOf), barValues(bars, closeOf), 14)}
+	}},
+}
+
+func findCandleIndicator(key rune) (candleIndicator, bool) {
+	for _, indicator := range candleIndicators {
+		if indicator.Key == key {
+			return indicator, true
+		}
+	}
+	return candleIndicator{}, false
+}
+
+func closeOf(b StockBar) float64 { return b.Close }
+func highOf(b StockBar) float64  { return b.High }
+func lowOf(b StockBar) float64   { return b.Low }
+
+func barValues(bars []StockBar, value func(StockBar) float64) []float64 {
+	values := make([]float64, len(bars))
+	for i, b := range bars {
+		values[i] = value(b)
+	}
+	return values
+}
+
+// toggle switches an indicator on or off
+func (c *candleChart) toggle(key rune) {
+	indicator, ok := findCandleIndicator(key)
+	switch {
+	case !ok:
+	case indicator.Overlay:
+		c.overlays[key] = !c.overlays[key]
+	case c.lower == key:
+		c.lower = 0
+	default:
+		c.lower = key
+	}
+}
+
+// shownIndicators are the indicators switched on, in the order listed
+func (c *candleChart) shownIndicators() []candleIndicator {
+	var shown []candleIndicator
+	for _, indicator := range candleIndicators {
+		if c.overlays[indicator.Key] || c.lower == indicator.Key {
+			shown = append(shown, indicator)
+		}
+	}
+	return shown
+}
+
+// SetBars shows bars and scrolls to the latest one
+func (c *candleChart) SetBars(title string, bars []StockBar) *candleChart {
+	c.title = title
+	c.bars = bars
+	c.values = make(map[rune][][]float64)
+	for _, indicator := range candleIndicators {
+		c.values[indicator.Key] = indicator.Compute(bars)
+	}
+	c.cursor = len(bars) - 1
+	c.span = 0
+	c.first = 0
+	c.message = ""
+	if len(bars) == 0 {
+		c.message = "No price history for " + title
+	}
+	return c
+}
+
+// SetMessage clears the chart and shows text in its place
+func (c *candleChart) SetMessage(text string) *candleChart {
+	c.bars = nil
+	c.message = text
+	return c
+}
+
+// visible is the number of bars that fit in width columns at the current
+// zoom. With room to spare, candles are spaced out by a blank column.
+func (c *candleChart) visible(width int) int {
+	if c.span > 0 {
+		return min(c.span, width, len(c.bars))
+	}
+	return min(max(width/2, 1), len(c.bars))
+}
+
+// scroll keeps the cursor on screen when count bars are visible
+func (c *candleChart) scroll(count int) {
+	c.first = min(max(c.first, c.cursor-count+1, 0), c.cursor, max(len(c.bars)-count, 0))
+}
+
+func (c *candleChart) Draw(screen tcell.Screen) {
+	c.Box.DrawForSubclass(screen, c)
+	x, y, width, height := c.GetInnerRect()
+	if width <= 0 || height <= 0 {
+		return
+	}
+	if len(c.bars) == 0 {
+		tview.Print(screen, tview.Escape(c.message), x, y, width, tview.AlignLeft, tview.Styles.SecondaryTextColor)
+		return
+	}
+
+	bar := c.bars[c.cursor]
+	change := 0.0
+	if bar.Open != 0 {
+		change = (bar.Close - bar.Open) / bar.Open * 100
+	}
+	readout := fmt.Sprintf("%s  %s  O %.2f  H %.2f  L %.2f  C %.2f (%+.2f%%)  V %s",
+		c.title, bar.Date, bar.Open, bar.High, bar.Low, bar.Close, change, formatVolume(bar.Volume))
+	for _, indicator := range c.shownIndicators() {
+		var values []string
+		for _, series := range c.values[indicator.Key] {
+			if v := series[c.cursor]; !math.IsNaN(v) {
+				values = append(values, fmt.Sprintf("%.2f", v))
+			}
+		}
+		if len(values) > 0 {
+			readout += fmt.Sprintf("  %s %s", indicator.Name, strings.Join(values, "/"))
+		}
+	}
+	tview.Print(screen, readout, x, y, width, tview.AlignLeft, tview.Styles.PrimaryTextColor)
+
+	// Price axis on the left, volume strip and date axis at the bottom
+	rows := height - 2
+	volumeRows := 0
+	if rows >= 8 {
+		volumeRows = max(rows/5, 2)
+		rows -= volumeRows
+	}
+	// The axis is sized for the widest price in the series so that it does
+	// not shift while panning
+	axisWidth := 0
+	for _, b := range c.bars {
+		axisWidth = max(axisWidth, len(fmt.Sprintf("%.2f", b.High))+1)
+	}
+	c.plotX, c.plotWidth = x+axisWidth, width-axisWidth
+	if rows < 1 || c.plotWidth < 1 {
+		return
+	}
+	count := c.visible(c.plotWidth)
+	c.scroll(count)
+	shown := c.bars[c.first : c.first+count]
+
+	low, high := shown[0].Low, shown[0].High
+	var maxVolume int64
+	for _, b := range shown {
+		low = math.Min(low, b.Low)
+		high = math.Max(high, b.High)
+		maxVolume = max(maxVolume, b.Volume)
+	}
+	for key, on := range c.overlays {
+		if !on {
+			continue
+		}
+		for _, series := range c.values[key] {
+			for _, v := range series[c.first : c.first+count] {
+				if !math.IsNaN(v) {
+					low, high = math.Min(low, v), math.Max(high, v)
+				}
+			}
+		}
+	}
+	highLabel, lowLabel := fmt.Sprintf("%.2f", high), fmt.Sprintf("%.2f", low)
+	tview.Print(screen, highLabel, x, y+1, axisWidth-1, tview.AlignRight, tview.Styles.SecondaryTextColor)
+	tview.Print(screen, lowLabel, x, y+rows, axisWidth-1, tview.AlignRight, tview.Styles.SecondaryTextColor)
+	if volumeRows > 0 && c.lower == 0 {
+		tview.Print(screen, formatVolume(maxVolume), x, y+rows+1, axisWidth-1, tview.AlignRight, tview.Styles.SecondaryTextColor)
+	}
+	dateRow := y + rows + volumeRows + 1
+	tview.Print(screen, shown[0].Date, c.plotX, dateRow, c.plotWidth, tview.AlignLeft, tview.Styles.SecondaryTextColor)
+	tview.Print(screen, shown[len(shown)-1].Date, c.plotX, dateRow, c.plotWidth, tview.AlignRight, tview.Styles.SecondaryTextColor)
+
+	// row maps a price to a row of the plot, the highest price on row 0
+	row := func(price float64) int {
+		if high == low {
+			return rows / 2
+		}
+		return int(math.Round((high - price) / (high - low) * float64(rows-1)))
+	}
+	for i, b := range shown {
+		col := c.plotX + i*c.plotWidth/len(shown)
+		color := tcell.ColorGreen
+		if b.Close < b.Open {
+			color = tcell.ColorRed
+		}
+		style := tcell.StyleDefault.Foreground(color)
+		if c.first+i == c.cursor {
+			style = style.Background(tcell.ColorDarkSlateGray)
+		}
+
+		bodyTop, bodyBottom := row(math.Max(b.Open, b.Close)), row(math.Min(b.Open, b.Close))
+		for r := 0; r < rows; r++ {
+			ch := ' '
+			switch {
+			case r >= bodyTop && r <= bodyBottom:
+				ch = '┃'
+			case r >= row(b.High) && r <= row(b.Low):
+				ch = '│'
+			}
+			screen.SetContent(col, y+1+r, ch, nil, style)
+		}
+
+		// Volume in eighths of a row
+		if volumeRows > 0 && c.lower == 0 && maxVolume > 0 {
+			eighths := int(b.Volume * int64(volumeRows*8) / maxVolume)
+			for r := 0; r < volumeRows; r++ {
+				level := min(max(eighths-(volumeRows-1-r)*8, 0), 8)
+				ch := ' '
+				if level > 0 {
+					ch = volumeBlocks[level-1]
+				}
+				screen.SetContent(col, y+1+rows+r, ch, nil, style.Foreground(tcell.ColorGray))
+			}
+		}
+	}
+
+	// Overlays go in the gaps of the candles they pass through
+	for _, indicator := range c.shownIndicators() {
+		if !indicator.Overlay {
+			continue
+		}
+		for s, series := range c.values[indicator.Key] {
+			for i := range shown {
+				v := series[c.first+i]
+				if math.IsNaN(v) {
+					continue
+				}
+				col, r := c.plotX+i*c.plotWidth/len(shown), y+1+row(v)
+				if ch, _, style, _ := screen.GetContent(col, r); ch == ' ' {
+					screen.SetContent(col, r, '•', nil, style.Foreground(indicator.Colors[s]))
+				}
+			}
+		}
+	}
+	if volumeRows > 0 && c.lower != 0 {
+		c.drawLower(screen, x, y+1+rows, axisWidth, volumeRows, len(shown))
+	}
+}
+
+// drawLower plots the indicator in place of the volume strip, scaled to its
+// visible values; RSI keeps its 0 to 100 scale.
+func (c *candleChart) drawLower(screen tcell.Screen, x, y, axisWidth, rows, count int) {
+	indicator, _ := findCandleIndicator(c.lower)
+	values := c.values[c.lower]
+	low, high := math.Inf(1), math.Inf(-1)
+	if c.lower == 'r' {
+		low, high = 0, 100
+	}
+	for _, series := range values {
+		for _, v := range series[c.first : c.first+count] {
+			if !math.IsNaN(v) {
+				low, high = math.Min(low, v), math.Max(high, v)
+			}
+		}
+	}
+	if math.IsInf(low, 0) {
+		tview.Print(screen, indicator.Name+": not enough history", c.plotX, y, c.plotWidth, tview.AlignLeft, tview.Styles.SecondaryTextColor)
+		return
+	}
+	tview.Print(screen, fmt.Sprintf("%.2f", high), x, y, axisWidth-1, tview.AlignRight, tview.Styles.SecondaryTextColor)
+	tview.Print(screen, fmt.Sprintf("%.2f", low), x, y+rows-1, axisWidth-1, tview.AlignRight, tview.Styles.SecondaryTextColor)
+	for s, series := range values {
+		for i := 0; i < count; i++ {
+			v := series[c.first+i]
+			if math.IsNaN(v) {
+				continue
+			}
+			r := rows / 2
+			if high > low {
+				r = int(math.Round((high - v) / (high - low) * float64(rows-1)))
+			}
+			style := tcell.StyleDefault.Foreground(indicator.Colors[s])
+			if c.first+i == c.cursor {
+				style = style.Background(tcell.ColorDarkSlateGray)
+			}
+			screen.SetContent(c.plotX+i*c.plotWidth/count, y+r, '•', nil, style)
+		}
+	}
+}
+
+var volumeBlocks = []rune("▁▂▃▄▅▆▇█")
+
+func formatVolume(volume int64) string {
+	switch {
+	case volume >= 1e9:
+		return fmt.Sprintf("%.1fB", float64(volume)/1e9)
+	case volume >= 1e6:
+		return fmt.Sprintf("%.1fM", float64(volume)/1e6)
+	case volume >= 1e3:
+		return fmt.Sprintf("%.1fK", float64(volume)/1e3)
+	}
+	return fmt.Sprint(volume)
+}
+
+// zoom shows factor times as many bars, keeping the cursor in place
+func (c *candleChart) zoom(factor float64) {
+	count := c.visible(c.plotWidth)
+	c.span = min(max(int(float64(count)*factor), 5), max(len(c.bars), 5), max(c.plotWidth, 5))
+	offset := c.cursor - c.first
+	c.first = c.cursor - int(float64(offset)*float64(c.span)/float64(max(count, 1)))
+	c.scroll(c.visible(c.plotWidth))
+}
+
+func (c *candleChart) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	return c.WrapInputHandler(func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if len(c.bars) == 0 {
+			return
+		}
+		count := c.visible(c.plotWidth)
+		switch event.Key() {
+		case tcell.KeyLeft:
+			c.cursor = max(c.cursor-1, 0)
+		case tcell.KeyRight:
+			c.cursor = min(c.cursor+1, len(c.bars)-1)
+		case tcell.KeyPgUp:
+			c.first = max(c.first-count, 0)
+			c.cursor = max(c.cursor-count, 0)
+		case tcell.KeyPgDn:
+			c.first = min(c.first+count, max(len(c.bars)-count, 0))
+			c.cursor = min(c.cursor+count, len(c.bars)-1)
+		case tcell.KeyHome:
+			c.cursor = 0
+		case tcell.KeyEnd:
+			c.cursor = len(c.bars) - 1
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case '+', '=':
+				c.zoom(0.5)
+			case '-', '_':
+				c.zoom(2)
+			default:
+				c.toggle(event.Rune())
+			}
+		}
+		c.scroll(c.visible(c.plotWidth))
+	})
+}
+
+func (c *candleChart) MouseHandler() func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+	return c.WrapMouseHandler(func(action tview.MouseAction, event *tcell.EventMouse, setFocus func(p tview.Primitive)) (consumed bool, capture tview.Primitive) {
+		if !c.InRect(event.Position()) {
+			return false, nil
+		}
+		switch action {
+		case tview.MouseLeftClick:
+			setFocus(c)
+			mx, _ := event.Position()
+			if count := c.visible(c.plotWidth); len(c.bars) > 0 && c.plotWidth > 0 && mx >= c.plotX {
+				c.cursor = min(c.first+(mx-c.plotX)*count/c.plotWidth, len(c.bars)-1)
+			}
+		case tview.MouseScrollUp:
+			c.zoom(0.5)
+		case tview.MouseScrollDown:
+			c.zoom(2)
+		default:
+			return false, nil
+		}
+		return true, nil
+	})
+}
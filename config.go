@@ -0,0 +1,396 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	serviceBudget  = "budget"
+	serviceSummary = "summary"
+	serviceStock   = "stock"
+	serviceCrypto  = "crypto"
+)
+
+var serviceNames = []string{serviceBudget, serviceSummary, serviceStock, serviceCrypto}
+
+// defaultSummaryIndices are the indices microservice-b has always reported
+var defaultSummaryIndices = []string{"^DJI", "^GSPC", "^IXIC"}
+
+// ServiceConfig describes how to reach one microservice. Input, Output, Inbox,
+// Outbox and Socket are resolved against Root unless they are absolute.
+type ServiceConfig struct {
+	Transport string `json:"transport"`
+	Root      string `json:"root"`
+	Input     string `json:"input"`
+	Output    string `json:"output"`
+	Inbox     string `json:"inbox"`
+	Outbox    string `json:"outbox"`
+	Sentinel  bool   `json:"sentinel"`
+	Socket    string `json:"socket"`
+	Method    string `json:"method"`
+	URL       string `json:"url"`
+	Handler   string `json:"handler"`
+
+	Timeout Duration `json:"timeout"`
+	Retries int      `json:"retries"`
+	Backoff Duration `json:"backoff"`
+
+	Heartbeat string `json:"heartbeat"`
+	Ping      bool   `json:"ping"`
+
+	// Command, if set, is started and supervised by the app
+	Command []string          `json:"command"`
+	Dir     string            `json:"dir"`
+	Env     map[string]string `json:"env"`
+
+	// explicit holds the keys of timeout, retries and backoff present in the
+	// config file, so that a zero there overrides the default
+	explicit map[string]bool
+}
+
+func (c *ServiceConfig) UnmarshalJSON(data []byte) error {
+	type plain ServiceConfig
+	if err := json.Unmarshal(data, (*plain)(c)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	c.explicit = make(map[string]bool)
+	for _, key := range []string{"timeout", "retries", "backoff"} {
+		_, c.explicit[key] = fields[key]
+	}
+	return nil
+}
+
+type Config struct {
+	Services          map[string]ServiceConfig `json:"services"`
+	HeartbeatInterval Duration                 `json:"heartbeat_interval"`
+	SummaryRefresh    Duration                 `json:"summary_refresh"`
+	SummaryIndices    []string                 `json:"summary_indices"`
+	WatchlistRefresh  Duration                 `json:"watchlist_refresh"`
+	SymbolDirectory   string                   `json:"symbol_directory"`
+	AlertCommand      []string                 `json:"alert_command"`
+	// CacheTTL is how long cached responses stay fresh, by contract; with
+	// "0s" they are only shown when the service is down
+	CacheTTL map[string]Duration `json:"cache_ttl"`
+}
+
+func defaultConfig() Config {
+	cfg := Config{
+		HeartbeatInterval: Duration{5 * time.Second},
+		SummaryRefresh:    Duration{time.Minute},
+		SummaryIndices:    defaultSummaryIndices,
+		WatchlistRefresh:  Duration{time.Minute},
+		CacheTTL:          make(map[string]Duration),
+		Services: map[string]ServiceConfig{
+			serviceBudget: {
+				Transport: "file",
+				Root:      "../sprint3/microservice-a",
+				Input:     "input.json",
+				Output:    "output.json",
+			},
+			serviceSummary: {
+				Transport: "file",
+				Root:      "../sprint3/microservice-b",
+				Input:     "input_summary.json",
+				Output:    "output_summary.json",
+			},
+			serviceStock: {
+				Transport: "file",
+				Root:      "../sprint3/microservice-c",
+				Input:     "input_stock.json",
+				Output:    "output_stock.json",
+			},
+			serviceCrypto: {
+				Transport: "file",
+				Root:      "../sprint3/microservice-d",
+				Input:     "input_crypto.json",
+				Output:    "output_crypto.json",
+			},
+		},
+	}
+	for contract, ttl := range defaultCacheTTL {
+		cfg.CacheTTL[contract] = Duration{ttl}
+	}
+	for name, svc := range cfg.Services {
+		svc.Timeout = Duration{10 * time.Second}
+		svc.Backoff = Duration{time.Second}
+		cfg.Services[name] = svc
+	}
+	return cfg
+}
+
+func (c ServiceConfig) inputPath() string {
+	return resolvePath(c.Root, c.Input)
+}
+
+func (c ServiceConfig) outputPath() string {
+	return resolvePath(c.Root, c.Output)
+}
+
+func (c ServiceConfig) inboxPath() string {
+	if c.Inbox == "" {
+		return resolvePath(c.Root, "inbox")
+	}
+	return resolvePath(c.Root, c.Inbox)
+}
+
+func (c ServiceConfig) outboxPath() string {
+	if c.Outbox == "" {
+		return resolvePath(c.Root, "outbox")
+	}
+	return resolvePath(c.Root, c.Outbox)
+}
+
+func (c ServiceConfig) socketPath() string {
+	return resolvePath(c.Root, c.Socket)
+}
+
+func resolvePath(root, name string) string {
+	if filepath.IsAbs(name) || root == "" {
+		return name
+	}
+	return filepath.Join(root, name)
+}
+
+// defaultConfigPath is $FINANCE_TUI_CONFIG if set, otherwise config.json in
+// the user's XDG config directory.
+func defaultConfigPath() string {
+	if path := os.Getenv("FINANCE_TUI_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "config.json"
+	}
+	return filepath.Join(dir, "go-finance-tui", "config.json")
+}
+
+// loadConfig reads the config file at path on top of the defaults. A missing
+// file is not an error. Relative service roots in the file are taken relative
+// to the file's own directory.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	var file Config
+	if err := json.Unmarshal(data, &file); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if file.HeartbeatInterval.Duration > 0 {
+		cfg.HeartbeatInterval = file.HeartbeatInterval
+	}
+	if file.SummaryRefresh.Duration > 0 {
+		cfg.SummaryRefresh = file.SummaryRefresh
+	}
+	if file.WatchlistRefresh.Duration > 0 {
+		cfg.WatchlistRefresh = file.WatchlistRefresh
+	}
+	if len(file.AlertCommand) > 0 {
+		cfg.AlertCommand = file.AlertCommand
+	}
+	if file.SymbolDirectory != "" {
+		cfg.SymbolDirectory = resolvePath(filepath.Dir(path), file.SymbolDirectory)
+	}
+	for contract, ttl := range file.CacheTTL {
+		if _, ok := cfg.CacheTTL[contract]; !ok {
+			return cfg, fmt.Errorf("%s: cache_ttl: no TTL can be set for %q", path, contract)
+		}
+		cfg.CacheTTL[contract] = ttl
+	}
+	if indices := normalizeTickers(file.SummaryIndices); len(indices) > 0 {
+		cfg.SummaryIndices = indices
+	}
+	for name, svc := range file.Services {
+		if _, ok := cfg.Services[name]; !ok {
+			return cfg, fmt.Errorf("%s: unknown service %q", path, name)
+		}
+		if svc.Root != "" && !filepath.IsAbs(svc.Root) {
+			svc.Root = filepath.Join(filepath.Dir(path), svc.Root)
+		}
+		if svc.Dir != "" && !filepath.IsAbs(svc.Dir) {
+			svc.Dir = filepath.Join(filepath.Dir(path), svc.Dir)
+		}
+		cfg.Services[name] = mergeService(cfg.Services[name], svc)
+		merged := cfg.Services[name]
+		if merged.Ping && !merged.independentRequests() {
+			return cfg, fmt.Errorf("%s: service %q: ping needs a spool, unix, http or inprocess transport; give the file transport a heartbeat file instead", path, name)
+		}
+		switch {
+		case merged.Timeout.Duration <= 0:
+			return cfg, fmt.Errorf("%s: service %q: timeout must be positive", path, name)
+		case merged.Retries < 0:
+			return cfg, fmt.Errorf("%s: service %q: retries cannot be negative", path, name)
+		case merged.Backoff.Duration < 0:
+			return cfg, fmt.Errorf("%s: service %q: backoff cannot be negative", path, name)
+		}
+	}
+	return cfg, nil
+}
+
+// independentRequests reports whether the transport can carry a request
+// alongside others. The file transport has one input file, so a ping would
+// hold up real requests and overwrite their input.
+func (c ServiceConfig) independentRequests() bool {
+	return c.Transport != "" && c.Transport != "file"
+}
+
+// registerServiceFlags adds -<service>-root, -<service>-input and
+// -<service>-output for every service. The returned overrides are filled in
+// once the flags are parsed.
+func registerServiceFlags(fs *flag.FlagSet) map[string]*ServiceConfig {
+	overrides := make(map[string]*ServiceConfig)
+	for _, name := range serviceNames {
+		o := &ServiceConfig{}
+		fs.StringVar(&o.Root, name+"-root", "", fmt.Sprintf("directory of the %s microservice", name))
+		fs.StringVar(&o.Input, name+"-input", "", fmt.Sprintf("input file of the %s microservice", name))
+		fs.StringVar(&o.Output, name+"-output", "", fmt.Sprintf("output file of the %s microservice", name))
+		overrides[name] = o
+	}
+	return overrides
+}
+
+// envOverrides reads FINANCE_TUI_<SERVICE>_ROOT, _INPUT and _OUTPUT.
+func envOverrides() map[string]*ServiceConfig {
+	overrides := make(map[string]*ServiceConfig)
+	for _, name := range serviceNames {
+		prefix := "FINANCE_TUI_" + strings.ToUpper(name) + "_"
+		overrides[name] = &ServiceConfig{
+			Root:   os.Getenv(prefix + "ROOT"),
+			Input:  os.Getenv(prefix + "INPUT"),
+			Output: os.Getenv(prefix + "OUTPUT"),
+		}
+	}
+	return overrides
+}
+
+func applyOverrides(cfg *Config, overrides map[string]*ServiceConfig) {
+	for name, o := range overrides {
+		cfg.Services[name] = mergeService(cfg.Services[name], *o)
+	}
+}
+
+// checkServiceDirs reports every file or spool service whose input or output
+// directory does not exist.
+func checkServiceDirs(cfg Config) []string {
+	var problems []string
+	for _, name := range serviceNames {
+		svc := cfg.Services[name]
+		var dirs []string
+		switch svc.Transport {
+		case "", "file":
+			dirs = []string{filepath.Dir(svc.inputPath())}
+			if outDir := filepath.Dir(svc.outputPath()); outDir != dirs[0] {
+				dirs = append(dirs, outDir)
+			}
+		case "spool":
+			dirs = []string{svc.inboxPath(), svc.outboxPath()}
+		}
+		for _, dir := range dirs {
+			if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+				problems = append(problems, fmt.Sprintf("%s: directory %s not found", name, dir))
+			}
+		}
+	}
+	return problems
+}
+
+func mergeService(base, override ServiceConfig) ServiceConfig {
+	if override.Transport != "" {
+		base.Transport = override.Transport
+	}
+	if override.Root != "" {
+		base.Root = override.Root
+	}
+	if override.Input != "" {
+		base.Input = override.Input
+	}
+	if override.Output != "" {
+		base.Output = override.Output
+	}
+	if override.Inbox != "" {
+		base.Inbox = override.Inbox
+	}
+	if override.Outbox != "" {
+		base.Outbox = override.Outbox
+	}
+	if override.Sentinel {
+		base.Sentinel = true
+	}
+	if override.Socket != "" {
+		base.Socket = override.Socket
+	}
+	if override.Method != "" {
+		base.Method = override.Method
+	}
+	if override.URL != "" {
+		base.URL = override.URL
+	}
+	if override.Handler != "" {
+		base.Handler = override.Handler
+	}
+	if override.Timeout.Duration > 0 || override.explicit["timeout"] {
+		base.Timeout = override.Timeout
+	}
+	if override.Retries > 0 || override.explicit["retries"] {
+		base.Retries = override.Retries
+	}
+	if override.Backoff.Duration > 0 || override.explicit["backoff"] {
+		base.Backoff = override.Backoff
+	}
+	if override.Heartbeat != "" {
+		base.Heartbeat = override.Heartbeat
+	}
+	if override.Ping {
+		base.Ping = true
+	}
+	if len(override.Command) > 0 {
+		base.Command = override.Command
+	}
+	if override.Dir != "" {
+		base.Dir = override.Dir
+	}
+	if len(override.Env) > 0 {
+		base.Env = override.Env
+	}
+	return base
+}
+
+// Duration is a time.Duration written as a string such as "10s" in JSON.
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
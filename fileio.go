@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	sentinelSuffix = ".done"
+	partialTimeout = 2 * time.Second
+	partialRetry   = 50 * time.Millisecond
+)
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// waitForFile blocks until path exists, re-checking whenever changed fires.
+func waitForFile(ctx context.Context, path string, changed <-chan struct{}) error {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// readCompleteJSON reads path, re-reading while the content looks like JSON
+// that is still being written. After partialTimeout the last content is
+// returned as is and left for the decoder to reject.
+func readCompleteJSON(ctx context.Context, path string, changed <-chan struct{}) ([]byte, error) {
+	deadline := time.Now().Add(partialTimeout)
+	for {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if !isTruncatedJSON(data) || time.Now().After(deadline) {
+			return data, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-changed:
+		case <-time.After(partialRetry):
+		}
+	}
+}
+
+// isTruncatedJSON reports whether data is empty or ends before its JSON value
+// does. Complete but malformed JSON, such as a stray closing brace, is not
+// truncated.
+func isTruncatedJSON(data []byte) bool {
+	if len(data) == 0 {
+		return true
+	}
+	var v interface{}
+	err := json.Unmarshal(data, &v)
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Error() == "unexpected end of JSON input"
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func sentinelPath(path string) string {
+	return path + sentinelSuffix
+}
@@ -0,0 +1,163 @@
+// Package indicators computes technical indicators over price series.
+//
+// Every function returns series as long as its input, lined up with it.
+// Positions without enough history for a value hold NaN.
+package indicators
+
+import "math"
+
+// SMA is the simple moving average of the last period values.
+func SMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	if period < 1 {
+		return out
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// EMA is the exponential moving average with a smoothing factor of
+// 2/(period+1), started from the simple average of the first period values.
+// Leading NaNs in values are skipped, so EMA can smooth another indicator.
+func EMA(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	if period < 1 || len(values)-start < period {
+		return out
+	}
+	alpha := 2 / float64(period+1)
+	sum := 0.0
+	for _, v := range values[start : start+period] {
+		sum += v
+	}
+	prev := sum / float64(period)
+	out[start+period-1] = prev
+	for i := start + period; i < len(values); i++ {
+		prev = alpha*values[i] + (1-alpha)*prev
+		out[i] = prev
+	}
+	return out
+}
+
+// RSI is Wilder's relative strength index, from 0 to 100. The first value
+// averages the gains and losses of the first period changes; later ones use
+// Wilder's smoothing.
+func RSI(closes []float64, period int) []float64 {
+	out := nanSeries(len(closes))
+	if period < 1 || len(closes) <= period {
+		return out
+	}
+	var gain, loss float64
+	for i := 1; i <= period; i++ {
+		change := closes[i] - closes[i-1]
+		gain += math.Max(change, 0)
+		loss += math.Max(-change, 0)
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+	out[period] = rsi(gain, loss)
+	for i := period + 1; i < len(closes); i++ {
+		change := closes[i] - closes[i-1]
+		gain = (gain*float64(period-1) + math.Max(change, 0)) / float64(period)
+		loss = (loss*float64(period-1) + math.Max(-change, 0)) / float64(period)
+		out[i] = rsi(gain, loss)
+	}
+	return out
+}
+
+func rsi(gain, loss float64) float64 {
+	if loss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+// MACD is the fast EMA less the slow EMA, the signal line is an EMA of that
+// and the histogram is their difference. The usual periods are 12, 26 and 9.
+func MACD(closes []float64, fast, slow, signal int) (macd, signalLine, histogram []float64) {
+	fastEMA, slowEMA := EMA(closes, fast), EMA(closes, slow)
+	macd = nanSeries(len(closes))
+	for i := range closes {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+	signalLine = EMA(macd, signal)
+	histogram = nanSeries(len(closes))
+	for i := range closes {
+		histogram[i] = macd[i] - signalLine[i]
+	}
+	return macd, signalLine, histogram
+}
+
+// Bollinger returns the simple moving average and the bands k population
+// standard deviations above and below it.
+func Bollinger(values []float64, period int, k float64) (middle, upper, lower []float64) {
+	middle = SMA(values, period)
+	upper, lower = nanSeries(len(values)), nanSeries(len(values))
+	for i := period - 1; i >= 0 && i < len(values); i++ {
+		variance := 0.0
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + k*sd
+		lower[i] = middle[i] - k*sd
+	}
+	return middle, upper, lower
+}
+
+// ATR is Wilder's average true range. The true range of the first bar is
+// its high less its low; the first ATR averages the first period true
+// ranges.
+func ATR(highs, lows, closes []float64, period int) []float64 {
+	n := min(len(highs), len(lows), len(closes))
+	out := nanSeries(n)
+	if period < 1 || n < period {
+		return out
+	}
+	trueRange := func(i int) float64 {
+		tr := highs[i] - lows[i]
+		if i > 0 {
+			tr = math.Max(tr, math.Max(math.Abs(highs[i]-closes[i-1]), math.Abs(lows[i]-closes[i-1])))
+		}
+		return tr
+	}
+	sum := 0.0
+	for i := 0; i < period; i++ {
+		sum += trueRange(i)
+	}
+	prev := sum / float64(period)
+	out[period-1] = prev
+	for i := period; i < n; i++ {
+		prev = (prev*float64(period-1) + trueRange(i)) / float64(period)
+		out[i] = prev
+	}
+	return out
+}
+
+// Last is the final value of a series, or NaN if it is empty.
+func Last(series []float64) float64 {
+	if len(series) == 0 {
+		return math.NaN()
+	}
+	return series[len(series)-1]
+}
+
+func nanSeries(n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = math.NaN()
+	}
+	return out
+}
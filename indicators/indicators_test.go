@@ -0,0 +1,148 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+// The SMA, EMA and RSI cases are the worked examples from StockCharts'
+// ChartSchool. Their tables round intermediate values to two places, hence
+// the tolerances.
+
+var stockChartsEMA = []float64{
+	22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+	22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63,
+	23.82, 23.87, 23.65, 23.19, 23.10, 23.33, 22.68, 23.10, 22.40, 22.17,
+}
+
+var stockChartsRSI = []float64{
+	44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+	45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64,
+	46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18, 44.22, 44.57,
+	43.42, 42.66, 43.13,
+}
+
+func TestSMA(t *testing.T) {
+	want := []float64{
+		22.22, 22.21, 22.23, 22.26, 22.31, 22.42, 22.61, 22.77, 22.91, 23.08,
+		23.21, 23.38, 23.53, 23.65, 23.71, 23.69, 23.61, 23.51, 23.43, 23.28, 23.13,
+	}
+	got := SMA(stockChartsEMA, 10)
+	checkLeadingNaN(t, "SMA", got, 9)
+	checkSeries(t, "SMA", got[9:], want, 0.01)
+}
+
+func TestEMA(t *testing.T) {
+	want := []float64{
+		22.22, 22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28,
+		23.34, 23.43, 23.51, 23.54, 23.47, 23.40, 23.39, 23.26, 23.23, 23.08, 22.92,
+	}
+	got := EMA(stockChartsEMA, 10)
+	checkLeadingNaN(t, "EMA", got, 9)
+	checkSeries(t, "EMA", got[9:], want, 0.01)
+}
+
+func TestEMASkipsLeadingNaN(t *testing.T) {
+	got := EMA([]float64{math.NaN(), math.NaN(), 1, 2, 3, 4}, 2)
+	checkLeadingNaN(t, "EMA", got, 3)
+	checkSeries(t, "EMA", got[3:], []float64{1.5, 2.5, 3.5}, 1e-9)
+}
+
+func TestRSI(t *testing.T) {
+	want := []float64{
+		70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+		54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+	}
+	got := RSI(stockChartsRSI, 14)
+	checkLeadingNaN(t, "RSI", got, 14)
+	checkSeries(t, "RSI", got[14:], want, 0.1)
+}
+
+func TestRSIWithoutLosses(t *testing.T) {
+	got := RSI([]float64{1, 2, 3, 4}, 2)
+	checkSeries(t, "RSI", got[2:], []float64{100, 100}, 0)
+}
+
+// On a steady ramp every EMA lags the price by (period-1)/2, so MACD(12, 26)
+// settles at 7 as soon as the slow EMA starts and the signal line follows it
+// exactly.
+func TestMACD(t *testing.T) {
+	ramp := make([]float64, 50)
+	for i := range ramp {
+		ramp[i] = float64(i)
+	}
+	macd, signal, histogram := MACD(ramp, 12, 26, 9)
+	checkLeadingNaN(t, "MACD", macd, 25)
+	checkLeadingNaN(t, "signal", signal, 33)
+	checkLeadingNaN(t, "histogram", histogram, 33)
+	for i := 25; i < len(ramp); i++ {
+		checkValue(t, "MACD", i, macd[i], 7, 1e-9)
+	}
+	for i := 33; i < len(ramp); i++ {
+		checkValue(t, "signal", i, signal[i], 7, 1e-9)
+		checkValue(t, "histogram", i, histogram[i], 0, 1e-9)
+	}
+}
+
+// 2, 4, 4, 4, 5, 5, 7, 9 has a mean of 5 and a population standard
+// deviation of 2
+func TestBollinger(t *testing.T) {
+	middle, upper, lower := Bollinger([]float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2)
+	checkLeadingNaN(t, "middle", middle, 7)
+	checkLeadingNaN(t, "upper", upper, 7)
+	checkLeadingNaN(t, "lower", lower, 7)
+	checkValue(t, "middle", 7, middle[7], 5, 1e-9)
+	checkValue(t, "upper", 7, upper[7], 9, 1e-9)
+	checkValue(t, "lower", 7, lower[7], 1, 1e-9)
+}
+
+func TestATR(t *testing.T) {
+	highs := []float64{10, 11, 12, 15, 13}
+	lows := []float64{8, 9, 10, 12, 11}
+	closes := []float64{9, 10, 11, 14, 12}
+	// True ranges are 2, 2, 2, then 4 (the gap up from 11 to 15) and 3 (the
+	// fall from 14 to 11)
+	got := ATR(highs, lows, closes, 3)
+	checkLeadingNaN(t, "ATR", got, 2)
+	checkSeries(t, "ATR", got[2:], []float64{2, 8.0 / 3, 25.0 / 9}, 1e-9)
+}
+
+func TestShortInput(t *testing.T) {
+	for name, series := range map[string][]float64{
+		"SMA": SMA([]float64{1, 2}, 3),
+		"EMA": EMA([]float64{1, 2}, 3),
+		"RSI": RSI([]float64{1, 2, 3}, 3),
+		"ATR": ATR([]float64{1, 2}, []float64{0, 1}, []float64{1, 2}, 3),
+	} {
+		checkLeadingNaN(t, name, series, len(series))
+	}
+	if !math.IsNaN(Last(nil)) {
+		t.Errorf("Last(nil) = %v, want NaN", Last(nil))
+	}
+}
+
+func checkLeadingNaN(t *testing.T, name string, got []float64, n int) {
+	t.Helper()
+	for i := 0; i < n && i < len(got); i++ {
+		if !math.IsNaN(got[i]) {
+			t.Errorf("%s[%d] = %v, want NaN", name, i, got[i])
+		}
+	}
+}
+
+func checkSeries(t *testing.T, name string, got, want []float64, tolerance float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s has %d values, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		checkValue(t, name, i, got[i], want[i], tolerance)
+	}
+}
+
+func checkValue(t *testing.T, name string, i int, got, want, tolerance float64) {
+	t.Helper()
+	if math.IsNaN(got) || math.Abs(got-want) > tolerance {
+		t.Errorf("%s[%d] = %.4f, want %.4f", name, i, got, want)
+	}
+}
@@ -2,36 +2,50 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"os"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/mattjmelnick/cs361-main/indicators"
 	"github.com/rivo/tview"
 )
 
 type IndexData struct {
-	Date   string
-	Open   float64
-	High   float64
-	Low    float64
-	Close  float64
-	Volume int64
-	Name   string
-	Ticker string
+	Date      string
+	Open      float64
+	High      float64
+	Low       float64
+	Close     float64
+	Volume    int64
+	Name      string
+	Ticker    string
+	PrevClose float64 `json:",omitempty"`
 }
 
 type StockData struct {
-	Date   string
-	Open   float64
-	Close  float64
-	High   float64
-	Low    float64
-	Ticker string
+	RequestID     string `json:"request_id"`
+	SchemaVersion int    `json:"schema_version"`
+	Date          string
+	Open          float64
+	Close         float64
+	High          float64
+	Low           float64
+	Ticker        string
+	Volume        int64 `json:",omitempty"`
+
+	// Indicators are worked out from the stock's history for show-more
+	Indicators *stockIndicators `json:"-"`
 }
 
 type BudgetCategory struct {
@@ -39,6 +53,11 @@ type BudgetCategory struct {
 	Percentage int
 }
 
+// flashDuration is how long changed summary cells stay highlighted
+const flashDuration = time.Second
+
+const dateLayout = "2006-01-02"
+
 var (
 	totalBudget         int
 	remainingPercentage = 100
@@ -46,35 +65,203 @@ var (
 )
 
 func main() {
+	configPath := flag.String("config", defaultConfigPath(), "path to the service configuration file")
+	flagOverrides := registerServiceFlags(flag.CommandLine)
+	embedded := flag.Bool("embedded", false, "use the built-in reference microservices")
+	flag.StringVar(&referenceDataDir, "data-dir", "", "directory with data files for the built-in microservices")
+	printSchemas := flag.Bool("dump-schemas", false, "print the JSON schemas of every microservice contract and exit")
+	recordPath := flag.String("record", "", "record every microservice exchange to this session archive")
+	replayPath := flag.String("replay", "", "answer requests from this session archive instead of the microservices")
+	replayTiming := flag.Bool("replay-timing", false, "reproduce recorded latencies when replaying")
+	summaryRefresh := flag.Duration("summary-refresh", 0, "how often the market summary refreshes (default from config, 1m)")
+	watchlistRefresh := flag.Duration("watchlist-refresh", 0, "how often the open watchlist refreshes (default from config, 1m)")
+	noCache := flag.Bool("no-cache", false, "always ask the microservices instead of the quote cache")
+	symbolsPath := flag.String("symbols", "", "CSV of tickers and company names for search and completion (default from config, built in)")
+	flag.Parse()
+
+	if *printSchemas {
+		schemas, err := dumpSchemas()
+		if err != nil {
+			log.Fatalf("Error rendering schemas: %v", err)
+		}
+		fmt.Println(string(schemas))
+		return
+	}
+
+	// Precedence: defaults, config file, environment, flags
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	applyOverrides(&cfg, envOverrides())
+	applyOverrides(&cfg, flagOverrides)
+	if *summaryRefresh > 0 {
+		cfg.SummaryRefresh = Duration{*summaryRefresh}
+	}
+	if *watchlistRefresh > 0 {
+		cfg.WatchlistRefresh = Duration{*watchlistRefresh}
+	}
+	if *symbolsPath != "" {
+		cfg.SymbolDirectory = *symbolsPath
+	}
+	symbols, err := loadSymbols(cfg.SymbolDirectory)
+	if err != nil {
+		log.Fatalf("Error loading symbol directory: %v", err)
+	}
+	if *embedded {
+		useReferenceServices(&cfg)
+	}
+	board := newStatusBoard(cfg)
+	services, err := buildServices(cfg, board)
+	if err != nil {
+		log.Fatalf("Error creating services: %v", err)
+	}
+
+	if *replayPath != "" {
+		session, err := loadSession(*replayPath)
+		if err != nil {
+			log.Fatalf("Error loading session: %v", err)
+		}
+		for name, t := range newReplayTransports(session, *replayTiming) {
+			services[name].Transport = t
+		}
+	}
+	if *recordPath != "" {
+		recorder, err := newSessionRecorder(*recordPath)
+		if err != nil {
+			log.Fatalf("Error creating session archive: %v", err)
+		}
+		defer recorder.Close()
+		for name, svc := range services {
+			svc.Transport = &recordingTransport{service: name, inner: svc.Transport, recorder: recorder}
+		}
+	}
+
+	// In-flight requests per page
+	var budgetPending, summaryPending, chartPending, stockPending, candlePending, indicatorPending, comparePending, watchPending, cryptoPending, alertPending pendingRequest
+
 	app := tview.NewApplication()
 
+	heartbeatCtx, stopHeartbeats := context.WithCancel(context.Background())
+	defer stopHeartbeats()
+	monitorHeartbeats(heartbeatCtx, services, board)
+
+	// Page headers show the US market session, updated every second
+	clock := newMarketClock(usMarket)
+	go clock.run(heartbeatCtx)
+
+	serviceLogs := newLogBuffer(500)
+	watcherLogs = serviceLogs
+
+	// Quotes are cached on disk, except when replaying a session
+	if !*noCache && *replayPath == "" {
+		ttl := make(map[string]time.Duration)
+		for contract, d := range cfg.CacheTTL {
+			ttl[contract] = d.Duration
+		}
+		quotes := loadQuoteCache(statePath(*configPath, "quotes.json"), ttl, serviceLogs)
+		for _, svc := range services {
+			svc.Cache = quotes
+		}
+	}
+
+	// The index list starts from the config and is saved whenever it changes
+	indicesPath := statePath(*configPath, "indices.json")
+	summaryIndices, err := loadSummaryIndices(indicesPath, cfg.SummaryIndices)
+	if err != nil {
+		log.Fatalf("Error loading index list: %v", err)
+	}
+	alertsPath := statePath(*configPath, "alerts.json")
+	alertBook, err := loadAlertBook(alertsPath)
+	if err != nil {
+		log.Fatalf("Error loading alerts: %v", err)
+	}
+	watchlistsPath := statePath(*configPath, "watchlists.json")
+	watch, err := loadWatchlists(watchlistsPath)
+	if err != nil {
+		log.Fatalf("Error loading watchlists: %v", err)
+	}
+
+	// Supervised microservices start after everything that can fail with
+	// log.Fatalf, which skips deferred calls, and are stopped once the app
+	// exits
+	sup := newSupervisor(serviceLogs, board)
+	if *replayPath == "" {
+		sup.start(cfg)
+	}
+	defer sup.stop()
+
 	// COMMAND TEXTS
 	mainCommandsText := (`COMMANDS
-	summary			Get a summary of the three major stock indices
+	summary			Get a summary of your stock indices
 	budget			Enter a budget
 	search-stocks   Search for stocks
 	search-crypto   Search for cryptocurrencies
+	watchlist       Track your watchlists
+	alerts          Set price alerts
+	status          Show microservice status and logs
 	quit            Quit the application`)
 
 	summaryCommandsText := (`COMMANDS
-	main		Go to main screen
-	quit		Quit the application`)
+	add TICKER...		Add indices or ETFs, e.g. add ^RUT ^FTSE
+	remove TICKER...	Remove indices or ETFs
+	reset				Go back to the Dow, S&P 500 and NASDAQ
+	chart TICKER		Chart the history of an index
+	range RANGE			Chart 1W, 1M, 6M, 1Y or 5Y
+	Tab					Go to the chart: ←/→ move the cursor, 1-5 pick the range
+	main				Go to main screen
+	quit				Quit the application`)
 
 	budgetCommandsText := (`COMMANDS
 	main		Go to main screen
 	quit		Quit the application`)
 
 	searchStocksCommandsText := (`COMMANDS
-	search $TICKER		Search for company
-	show-more			Show additional price details
+	search $TICKER...	Search for one or more companies
+	sort COLUMN			Sort by a column, again to reverse
+	show-more			Show additional price details and indicators
+	compare [RANGE]		Compare performance over 1W, 1M, 6M, 1Y or 5Y
+	chart $TICKER [RANGE] [INTERVAL]	Candlesticks over a range in 1d, 1wk or 1mo bars
+	Tab					Go to the chart: ←/→ move, +/- zoom, PgUp/PgDn pan, s e b r m a indicators
+	watch [LIST]		Add the searched stocks to a watchlist
+	unwatch [LIST]		Remove the searched stocks from a watchlist
 	main				Go to main screen
 	quit            	Quit the application`)
 
+	compareCommandsText := (`COMMANDS
+	range RANGE		Compare over 1W, 1M, 6M, 1Y or 5Y
+	Tab				Go to the chart: ←/→ move the cursor, 1-5 pick the range
+	search			Go back to the stock search
+	main			Go to main screen
+	quit			Quit the application`)
+
+	watchlistCommandsText := (`COMMANDS
+	open LIST			Open a watchlist, creating it if it is new
+	add $TICKER...		Add stocks to the open watchlist
+	remove $TICKER...	Remove stocks from the open watchlist
+	delete LIST			Delete a watchlist
+	refresh				Refresh the open watchlist now
+	search				Go to the stock search
+	main				Go to main screen
+	quit				Quit the application`)
+
 	searchCryptoCommandsText := (`COMMANDS
 	search COIN			Search for cryptocurrency
 	main				Go to main screen
 	quit            	Quit the application`)
 
+	alertsCommandsText := (`COMMANDS
+	add RULE			Add an alert, e.g. add AAPL close > 200 or add bitcoin falls 5% in 24h
+	edit N RULE			Replace alert N
+	remove N			Remove alert N
+	rearm N				Arm a triggered alert again
+	main				Go to main screen
+	quit				Quit the application`)
+
+	statusCommandsText := (`COMMANDS
+	main		Go to main screen
+	quit		Quit the application`)
+
 	// MAIN PAGE
 	mainTitle := tview.NewTextView().
 		SetText("Go Finance TUI")
@@ -84,24 +271,32 @@ func main() {
 
 	mainCommands := tview.NewTextView().SetText(mainCommandsText)
 
+	mainStatus := tview.NewTextView().SetTextColor(tcell.ColorRed)
+	if problems := checkServiceDirs(cfg); len(problems) > 0 && *replayPath == "" {
+		mainStatus.SetText(strings.Join(problems, "\n"))
+	}
+
 	mainInput := tview.NewInputField().
 		SetLabel("→ ").
 		SetFieldWidth(30)
 
 	mainLayout := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(mainTitle, 3, 1, false).
+		AddItem(newPageHeader(app, mainTitle, board, clock), 3, 1, false).
 		AddItem(mainDescription, 3, 1, false).
-		AddItem(mainCommands, 8, 1, false).
-		AddItem(mainInput, 1, 1, true)
+		AddItem(mainCommands, 11, 1, false).
+		AddItem(mainInput, 1, 1, true).
+		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
+		AddItem(mainStatus, 0, 1, false)
 
 	// SUMMARY PAGE
 	summaryWaiting := tview.NewTextView().SetText("Waiting for data...")
+	summaryUpdated := tview.NewTextView()
 
 	summaryTitle := tview.NewTextView().
 		SetText("Major Stock Indices")
 
 	summaryDescription := tview.NewTextView().
-		SetText(`Summary of the Dow Jones, S&P 500, and NASDAQ stock indices`)
+		SetText(`Summary of your chosen stock indices and ETFs`)
 
 	summaryCommands := tview.NewTextView().SetText(summaryCommandsText)
 
@@ -110,16 +305,133 @@ func main() {
 		SetFieldWidth(30)
 
 	indicesTable := tview.NewTable().SetBorders(true)
+	summaryChartRanges := tview.NewTextView().SetDynamicColors(true)
+	summaryChart := newLineChart()
 
 	summaryLayout := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(summaryTitle, 3, 1, false).
+		AddItem(newPageHeader(app, summaryTitle, board, clock), 3, 1, false).
 		AddItem(summaryDescription, 2, 1, false).
 		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
-		AddItem(summaryCommands, 5, 1, false).
+		AddItem(summaryCommands, 10, 1, false).
 		AddItem(summaryInput, 1, 1, true).
 		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
 		AddItem(summaryWaiting, 1, 1, false).
-		AddItem(indicesTable, 0, 1, true)
+		AddItem(summaryUpdated, 1, 1, false).
+		AddItem(indicesTable, 0, 1, false).
+		AddItem(summaryChartRanges, 1, 1, false).
+		AddItem(summaryChart, 0, 2, false)
+
+	// The summary re-requests microservice-b every cfg.SummaryRefresh while
+	// the page is open
+	summaryTracker := newSummaryTracker()
+
+	// The chart below the table shows the history of one index. Ranges end on
+	// the latest day the summary has for it; history is only requested again
+	// when the index, the range or that day changes.
+	chartTicker := ""
+	chartRangeIndex := 1
+	chartLoaded := ""
+	loadChart := func() {
+		if len(summaryIndices) == 0 {
+			return
+		}
+		if !slices.Contains(summaryIndices, chartTicker) {
+			chartTicker = summaryIndices[0]
+		}
+		end := time.Now()
+		if latest, ok := summaryTracker.last[chartTicker]; ok {
+			if day, err := time.Parse(dateLayout, latest.Date); err == nil {
+				end = day
+			}
+		}
+		chartRange := chartRanges[chartRangeIndex]
+		renderChartRanges(summaryChartRanges, chartTicker, chartRangeIndex)
+
+		key := chartTicker + " " + chartRange.Label + " " + end.Format(dateLayout)
+		if key == chartLoaded {
+			return
+		}
+		chartLoaded = key
+		ticker := chartTicker
+		summaryChart.SetMessage(fmt.Sprintf("Loading %s history...", ticker))
+		waitForIndexHistory(chartPending.start(), app, services[serviceSummary], ticker, chartRange.start(end), end, func(series []IndexData, age quoteAge) {
+			summaryChart.SetSeries(age.titled(ticker, time.Now()), closingPrices(series))
+		}, func(err error) {
+			chartLoaded = ""
+			summaryChart.SetMessage(fmt.Sprintf("Could not load %s history: %v", ticker, err))
+		}, func(err error) {
+			summaryChart.SetMessage(fmt.Sprintf("Summary service: %v, still waiting...", err))
+		})
+	}
+	summaryChart.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyTab || event.Key() == tcell.KeyEscape:
+			app.SetFocus(summaryInput)
+			return nil
+		case event.Rune() >= '1' && int(event.Rune()-'1') < len(chartRanges):
+			chartRangeIndex = int(event.Rune() - '1')
+			loadChart()
+			return nil
+		}
+		return event
+	})
+
+	summaryGeneration := 0
+	var refreshSummary func(ctx context.Context)
+	scheduleSummaryRefresh := func(ctx context.Context) {
+		time.AfterFunc(cfg.SummaryRefresh.Duration, func() {
+			app.QueueUpdate(func() {
+				if ctx.Err() == nil {
+					refreshSummary(ctx)
+				}
+			})
+		})
+	}
+	refreshSummary = func(ctx context.Context) {
+		requested := summaryIndices
+		waitForSummaryData(ctx, app, services[serviceSummary], requested, func(indices []IndexData, age quoteAge) {
+			rows := summaryTracker.update(indices, time.Now())
+			summaryGeneration++
+			generation := summaryGeneration
+			renderSummaryTable(indicesTable, rows, true)
+			summaryLayout.ResizeItem(indicesTable, 2*len(rows)+3, 0)
+			summaryWaiting.SetText("")
+			if missing := missingIndices(requested, indices); len(missing) > 0 {
+				summaryWaiting.SetText("No data for " + strings.Join(missing, ", "))
+			}
+			if age.Err != nil {
+				summaryWaiting.SetText(fmt.Sprintf("Summary service error: %v", age.Err))
+			}
+			updated := time.Now()
+			if age.Cached {
+				updated = age.At
+			}
+			summaryUpdated.SetText(fmt.Sprintf("Market Summary - last updated %s, refreshing every %s",
+				updated.Format("15:04:05"), cfg.SummaryRefresh.Duration))
+			if label := age.label(time.Now()); label != "" {
+				summaryUpdated.SetText(summaryUpdated.GetText(false) + " (" + label + ")")
+			}
+
+			// Drop the highlight of changed cells after a moment
+			time.AfterFunc(flashDuration, func() {
+				app.QueueUpdateDraw(func() {
+					if generation == summaryGeneration {
+						renderSummaryTable(indicesTable, rows, false)
+					}
+				})
+			})
+			loadChart()
+			scheduleSummaryRefresh(ctx)
+		}, func(err error) {
+			if summaryTracker.empty() {
+				renderErrorTable(indicesTable, "Failed to load summary", err)
+			}
+			summaryWaiting.SetText(fmt.Sprintf("Summary service error: %v", err))
+			scheduleSummaryRefresh(ctx)
+		}, func(err error) {
+			summaryWaiting.SetText(fmt.Sprintf("Summary service: %v, still waiting...", err))
+		})
+	}
 
 	// BUDGET PAGE
 	budgetTitle := tview.NewTextView().
@@ -164,6 +476,10 @@ func main() {
 				budgetMessage.SetText("Category already exists.")
 				return
 			}
+			if slices.Contains(reservedBudgetKeys, category) {
+				budgetMessage.SetText(fmt.Sprintf("%q is reserved, choose another category name.", category))
+				return
+			}
 			app.SetFocus(percentageInput)
 		}
 	})
@@ -192,23 +508,24 @@ func main() {
 			percentageInput.SetText("")
 
 			if remainingPercentage == 0 {
-				budgetMessage.SetText("Budget allocated: Writing to file...")
-				err = saveBudgetToFile("../sprint3/microservice-a/input.json", totalBudget, budgetCategories)
-				if err != nil {
-					budgetMessage.SetText("Failed to write file.")
-				} else {
+				budgetMessage.SetText("Budget allocated: Sending to budget service...")
+				waitForBudgetOutput(budgetPending.start(), app, services[serviceBudget], totalBudget, budgetCategories, func(budget map[string]int) {
+					renderBudgetTable(budgetTable, budget)
 					budgetMessage.SetText("Budget saved successfully.")
-				}
+				}, func(err error) {
+					renderErrorTable(budgetTable, "Failed to read budget output", err)
+					budgetMessage.SetText(fmt.Sprintf("Budget service error: %v", err))
+				}, func(err error) {
+					budgetMessage.SetText(fmt.Sprintf("Budget service: %v, still waiting...", err))
+				})
 			}
 
 			app.SetFocus(categoryInput)
-
-			go waitForBudgetOutput(app, "../sprint3/microservice-a/output.json", budgetTable)
 		}
 	})
 
 	budgetLayout := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(budgetTitle, 3, 1, false).
+		AddItem(newPageHeader(app, budgetTitle, board, clock), 3, 1, false).
 		AddItem(budgetDescription, 2, 1, false).
 		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
 		AddItem(budgetCommands, 5, 1, false).
@@ -220,6 +537,63 @@ func main() {
 		AddItem(categoryTable, 0, 1, false).
 		AddItem(budgetTable, 0, 1, false)
 
+	// ALERTS PAGE
+	alertsTitle := tview.NewTextView().
+		SetText("Price Alerts")
+
+	alertsDescription := tview.NewTextView().
+		SetText(`Alerts are checked whenever stock quotes or crypto prices arrive: from searches,
+the open watchlist while its page is showing, or the crypto page. Each alert fires
+once until it is re-armed.`)
+
+	alertsCommands := tview.NewTextView().SetText(alertsCommandsText)
+
+	alertsInput := tview.NewInputField().
+		SetLabel("→ ").
+		SetFieldWidth(60)
+
+	alertsMessage := tview.NewTextView()
+	alertsTable := tview.NewTable().
+		SetBorders(true).
+		SetFixed(1, 0)
+
+	alertsLayout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(newPageHeader(app, alertsTitle, board, clock), 3, 1, false).
+		AddItem(alertsDescription, 3, 1, false).
+		AddItem(alertsCommands, 7, 1, false).
+		AddItem(alertsInput, 1, 1, true).
+		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
+		AddItem(alertsMessage, 1, 1, false).
+		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
+		AddItem(alertsTable, 0, 1, false)
+
+	saveAlerts := func() {
+		if err := saveState(alertsPath, alertBook); err != nil {
+			alertsMessage.SetText(fmt.Sprintf("Could not save alerts: %v", err))
+		}
+	}
+	renderAlertsTable(alertsTable, alertBook.Alerts)
+
+	notifications := newToast()
+	app.SetAfterDrawFunc(notifications.draw)
+
+	// checkAlerts is handed every fresh quote, whichever page asked for it
+	checkAlerts := func(q alertQuote) {
+		fired, messages := alertBook.observe(q)
+		if len(fired) > 0 {
+			notifications.show(app, messages...)
+			for i, alert := range fired {
+				if len(cfg.AlertCommand) > 0 {
+					go runAlertCommand(cfg.AlertCommand, alert, messages[i], serviceLogs)
+				}
+			}
+		}
+		if slices.ContainsFunc(alertBook.Alerts, func(r alertRule) bool { return r.key() == q.key() }) {
+			renderAlertsTable(alertsTable, alertBook.Alerts)
+			saveAlerts()
+		}
+	}
+
 	// SEARCH STOCKS PAGE
 	searchStocksWaiting := tview.NewTextView().
 		SetText("Enter stock ticker")
@@ -228,31 +602,333 @@ func main() {
 		SetText("Search Stocks")
 
 	searchStocksDescription := tview.NewTextView().
-		SetText(`Search for stocks using the ticker symbol
+		SetText(`Search for stocks using their ticker symbols
 
-Example: search $AAPL`)
+Example: search $AAPL $MSFT $GOOG, then chart $AAPL 1Y 1wk`)
 
 	searchStocksCommands := tview.NewTextView().SetText(searchStocksCommandsText)
 
 	searchStocksInput := tview.NewInputField().
 		SetLabel("→ ").
 		SetFieldWidth(30)
+	completeTickers(searchStocksInput, symbols, "search", "chart")
 
 	searchStocksTable := tview.NewTable().
 		SetBorders(true).
 		SetFixed(1, 0)
 
+	candles := newCandleChart().SetMessage("chart $TICKER [RANGE] [INTERVAL] to see price history")
+
 	var showMore bool = false
+	var stockResults []StockData
+	stockOrder := stockSort{Column: -1}
 
 	searchStocksLayout := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(searchStocksTitle, 3, 1, false).
-		AddItem(searchStocksDescription, 5, 1, false).
-		AddItem(searchStocksCommands, 7, 1, false).
+		AddItem(newPageHeader(app, searchStocksTitle, board, clock), 3, 1, false).
+		AddItem(searchStocksDescription, 3, 1, false).
+		AddItem(searchStocksCommands, 12, 1, false).
 		AddItem(searchStocksInput, 1, 1, true).
 		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
 		AddItem(searchStocksWaiting, 1, 1, false).
 		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
-		AddItem(searchStocksTable, 0, 1, false)
+		AddItem(searchStocksTable, 3, 0, false).
+		AddItem(candles, 0, 2, false)
+
+	// fitStockTable shrinks the table to its rows so the chart gets the rest
+	fitStockTable := func() {
+		searchStocksLayout.ResizeItem(searchStocksTable, 2*searchStocksTable.GetRowCount()+1, 0)
+	}
+	showStocks := func() {
+		renderStockTable(searchStocksTable, stockResults, showMore, stockOrder)
+		fitStockTable()
+	}
+
+	// loadCandles requests one stock's bars over a range ending on its latest
+	// quote, or today if it has not been searched for
+	loadCandles := func(ticker string, chartRange chartRange, interval string) {
+		end := time.Now()
+		for _, stock := range stockResults {
+			if strings.EqualFold(stock.Ticker, ticker) {
+				if day, err := time.Parse(dateLayout, stock.Date); err == nil {
+					end = day
+				}
+			}
+		}
+		title := fmt.Sprintf("%s %s %s", ticker, chartRange.Label, interval)
+		candles.SetMessage(fmt.Sprintf("Loading %s history...", ticker))
+		waitForStockHistory(candlePending.start(), app, services[serviceStock], ticker, chartRange.start(end), end, interval, func(bars []StockBar, age quoteAge) {
+			candles.SetBars(age.titled(title, time.Now()), bars)
+		}, func(err error) {
+			candles.SetMessage(fmt.Sprintf("Could not load %s history: %v", ticker, err))
+		}, func(err error) {
+			candles.SetMessage(fmt.Sprintf("Stock service: %v, still waiting...", err))
+		})
+	}
+	// loadIndicators requests the daily history of every searched stock that
+	// has no indicators yet, for the show-more columns
+	loadIndicators := func() {
+		ctx := indicatorPending.start()
+		for _, stock := range stockResults {
+			if stock.Indicators != nil {
+				continue
+			}
+			ticker := stock.Ticker
+			end := time.Now()
+			if day, err := time.Parse(dateLayout, stock.Date); err == nil {
+				end = day
+			}
+			waitForStockHistory(ctx, app, services[serviceStock], ticker, indicatorHistory.start(end), end, "1d", func(bars []StockBar, _ quoteAge) {
+				for i := range stockResults {
+					if stockResults[i].Ticker == ticker {
+						stockResults[i].Indicators = latestIndicators(bars)
+					}
+				}
+				showStocks()
+			}, func(err error) {
+				searchStocksWaiting.SetText(fmt.Sprintf("Could not load %s history: %v", ticker, err))
+			}, func(err error) {
+				searchStocksWaiting.SetText(fmt.Sprintf("Stock service: %v, still waiting...", err))
+			})
+		}
+	}
+	candles.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyTab || event.Key() == tcell.KeyEscape {
+			app.SetFocus(searchStocksInput)
+			return nil
+		}
+		return event
+	})
+
+	// COMPARE PAGE
+	compareTitle := tview.NewTextView().
+		SetText("Compare Stocks")
+
+	compareDescription := tview.NewTextView().
+		SetText("Performance of the searched stocks as the change in price since the start of the period")
+
+	compareCommands := tview.NewTextView().SetText(compareCommandsText)
+
+	compareInput := tview.NewInputField().
+		SetLabel("→ ").
+		SetFieldWidth(30)
+
+	compareWaiting := tview.NewTextView()
+	compareRanges := tview.NewTextView().SetDynamicColors(true)
+	compareChart := newLineChart()
+	compareTable := tview.NewTable().SetBorders(true)
+
+	compareLayout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(newPageHeader(app, compareTitle, board, clock), 3, 1, false).
+		AddItem(compareDescription, 2, 1, false).
+		AddItem(compareCommands, 7, 1, false).
+		AddItem(compareInput, 1, 1, true).
+		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
+		AddItem(compareWaiting, 1, 1, false).
+		AddItem(compareRanges, 1, 1, false).
+		AddItem(compareChart, 0, 2, false).
+		AddItem(compareTable, 0, 1, false)
+
+	// Each compared stock's history is requested separately; the chart is
+	// drawn once they have all answered. The period ends on the date of the
+	// latest quote searched for.
+	var compareTickers []string
+	compareRangeIndex := 1
+	loadCompare := func() {
+		renderChartRanges(compareRanges, strings.Join(compareTickers, " "), compareRangeIndex)
+		end := time.Now()
+		if len(stockResults) > 0 {
+			latest := slices.MaxFunc(stockResults, func(a, b StockData) int { return strings.Compare(a.Date, b.Date) })
+			if day, err := time.Parse(dateLayout, latest.Date); err == nil {
+				end = day
+			}
+		}
+		chartRange := chartRanges[compareRangeIndex]
+
+		ctx := comparePending.start()
+		histories := make(map[string][]StockBar)
+		var failed []string
+		remaining := len(compareTickers)
+		done := func() {
+			remaining--
+			if remaining > 0 {
+				return
+			}
+			var loaded []string
+			for _, ticker := range compareTickers {
+				if _, ok := histories[ticker]; ok {
+					loaded = append(loaded, ticker)
+				}
+			}
+			series, results := rebaseHistories(loaded, histories)
+			colors := make(map[string]tcell.Color)
+			for _, s := range series {
+				colors[s.Name] = s.Color
+			}
+			if len(series) == 0 {
+				compareChart.SetMessage("No price history to compare")
+			} else {
+				compareChart.SetComparison(series)
+			}
+			renderCompareTable(compareTable, results, colors)
+			compareLayout.ResizeItem(compareTable, 2*len(results)+3, 0)
+			compareWaiting.SetText(strings.Join(failed, "; "))
+		}
+
+		compareWaiting.SetText("Waiting for price history...")
+		compareChart.SetMessage("Loading...")
+		compareTable.Clear()
+		for _, ticker := range compareTickers {
+			waitForStockHistory(ctx, app, services[serviceStock], ticker, chartRange.start(end), end, "1d", func(bars []StockBar, age quoteAge) {
+				histories[ticker] = bars
+				if age.Err != nil {
+					failed = append(failed, fmt.Sprintf("%s: %s", ticker, age.label(time.Now())))
+				}
+				done()
+			}, func(err error) {
+				failed = append(failed, fmt.Sprintf("%s: %v", ticker, err))
+				done()
+			}, func(err error) {
+				compareWaiting.SetText(fmt.Sprintf("Stock service: %v, still waiting...", err))
+			})
+		}
+	}
+	compareChart.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch {
+		case event.Key() == tcell.KeyTab || event.Key() == tcell.KeyEscape:
+			app.SetFocus(compareInput)
+			return nil
+		case event.Rune() >= '1' && int(event.Rune()-'1') < len(chartRanges):
+			compareRangeIndex = int(event.Rune() - '1')
+			loadCompare()
+			return nil
+		}
+		return event
+	})
+
+	// WATCHLIST PAGE
+	watchlistTitle := tview.NewTextView().
+		SetText("Watchlists")
+
+	watchlistDescription := tview.NewTextView().SetDynamicColors(true)
+
+	watchlistCommands := tview.NewTextView().SetText(watchlistCommandsText)
+
+	watchlistInput := tview.NewInputField().
+		SetLabel("→ ").
+		SetFieldWidth(30)
+	completeTickers(watchlistInput, symbols, "add", "remove")
+
+	watchlistWaiting := tview.NewTextView()
+	watchlistUpdated := tview.NewTextView()
+	watchlistTable := tview.NewTable().
+		SetBorders(true).
+		SetFixed(1, 0)
+
+	watchlistLayout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(newPageHeader(app, watchlistTitle, board, clock), 3, 1, false).
+		AddItem(watchlistDescription, 2, 1, false).
+		AddItem(watchlistCommands, 9, 1, false).
+		AddItem(watchlistInput, 1, 1, true).
+		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
+		AddItem(watchlistWaiting, 1, 1, false).
+		AddItem(watchlistUpdated, 1, 1, false).
+		AddItem(watchlistTable, 0, 1, false)
+
+	watchQuotes := make(map[string]watchQuote)
+
+	showWatchlist := func() {
+		var others []string
+		for _, name := range watch.names()[1:] {
+			others = append(others, fmt.Sprintf("%s (%d)", name, len(watch.Lists[name])))
+		}
+		description := fmt.Sprintf("Watchlist [::b]%s[::-]", watch.Current)
+		if len(others) > 0 {
+			description += ", also " + strings.Join(others, ", ")
+		}
+		watchlistDescription.SetText(description)
+		renderWatchlistTable(watchlistTable, watch.tickers(), watchQuotes)
+		if len(watch.tickers()) == 0 {
+			watchlistWaiting.SetText("add $TICKER... to start watching stocks")
+		}
+	}
+	saveWatchlists := func() {
+		if err := saveState(watchlistsPath, watch); err != nil {
+			watchlistWaiting.SetText(fmt.Sprintf("Could not save watchlists: %v", err))
+		}
+	}
+
+	// The open watchlist is re-requested from microservice-c every
+	// cfg.WatchlistRefresh while the watchlist page is showing, one request per
+	// ticker. The next round is scheduled once every ticker has answered.
+	var refreshWatchlist func(ctx context.Context)
+	scheduleWatchlistRefresh := func(ctx context.Context) {
+		time.AfterFunc(cfg.WatchlistRefresh.Duration, func() {
+			app.QueueUpdateDraw(func() {
+				if ctx.Err() == nil {
+					refreshWatchlist(ctx)
+				}
+			})
+		})
+	}
+	refreshWatchlist = func(ctx context.Context) {
+		tickers := watch.tickers()
+		remaining := len(tickers)
+		var failed []string
+		done := func() {
+			remaining--
+			if remaining > 0 {
+				return
+			}
+			showWatchlist()
+			watchlistWaiting.SetText("")
+			if len(failed) > 0 {
+				watchlistWaiting.SetText("Stock service error: " + strings.Join(failed, "; "))
+			}
+			watchlistUpdated.SetText(fmt.Sprintf("Last refreshed %s, refreshing every %s",
+				time.Now().Format("15:04:05"), cfg.WatchlistRefresh.Duration))
+			scheduleWatchlistRefresh(ctx)
+		}
+		if len(tickers) == 0 {
+			showWatchlist()
+			scheduleWatchlistRefresh(ctx)
+			return
+		}
+		for _, ticker := range tickers {
+			waitForStockData(ctx, app, services[serviceStock], ticker, cacheFallback, func(data StockData, age quoteAge) {
+				if age.Cached {
+					watchQuotes[ticker] = watchQuote{StockData: data, Updated: age.At, Err: age.Err}
+					if age.Err != nil {
+						failed = append(failed, fmt.Sprintf("%s: %v", ticker, age.Err))
+					}
+				} else {
+					watchQuotes[ticker] = watchQuote{StockData: data, Updated: time.Now()}
+					checkAlerts(stockAlertQuote(data, time.Now()))
+				}
+				done()
+			}, func(err error) {
+				quote := watchQuotes[ticker]
+				quote.Err = err
+				watchQuotes[ticker] = quote
+				failed = append(failed, fmt.Sprintf("%s: %s", ticker, symbols.didYouMean(ticker, err)))
+				done()
+			}, func(err error) {
+				watchlistWaiting.SetText(fmt.Sprintf("Stock service: %v, still waiting...", err))
+			})
+		}
+	}
+	// The open list is only refreshed while the watchlist page is showing,
+	// so that its requests do not queue up ahead of the other pages'
+	watchlistShown := false
+	restartWatchlist := func() {
+		showWatchlist()
+		if watchlistShown {
+			refreshWatchlist(watchPending.start())
+		}
+	}
+	leaveWatchlist := func() {
+		watchlistShown = false
+		watchPending.stop()
+	}
 
 	// SEARCH CRYPTO PAGE
 	searchCryptoWaiting := tview.NewTextView().
@@ -277,7 +953,7 @@ Example: search bitcoin`)
 		SetFixed(1, 0)
 
 	searchCryptoLayout := tview.NewFlex().SetDirection(tview.FlexRow).
-		AddItem(searchCryptoTitle, 3, 1, false).
+		AddItem(newPageHeader(app, searchCryptoTitle, board, clock), 3, 1, false).
 		AddItem(searchCryptoDescription, 5, 1, false).
 		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
 		AddItem(searchCryptoCommands, 7, 1, false).
@@ -287,13 +963,64 @@ Example: search bitcoin`)
 		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
 		AddItem(searchCryptoTable, 0, 1, false)
 
+	// STATUS PAGE
+	statusTitle := tview.NewTextView().
+		SetText("Microservice Status")
+
+	statusDescription := tview.NewTextView().
+		SetText("Configured paths, heartbeat, latency and last error of each microservice, followed by the output of supervised services")
+
+	statusCommands := tview.NewTextView().SetText(statusCommandsText)
+
+	statusInput := tview.NewInputField().
+		SetLabel("→ ").
+		SetFieldWidth(30)
+
+	statusTable := tview.NewTable().SetBorders(true)
+	renderStatusTable(statusTable, board.snapshot(), board.interval)
+	board.subscribe(func() {
+		go app.QueueUpdateDraw(func() {
+			renderStatusTable(statusTable, board.snapshot(), board.interval)
+		})
+	})
+
+	// Lines logged while starting up, e.g. by the quote cache, are shown too
+	statusLogs := tview.NewTextView().SetScrollable(true).SetText(serviceLogs.String())
+	statusLogs.SetBorder(true).SetTitle("Service logs")
+
+	var logsQueued atomic.Bool
+	serviceLogs.onChange = func() {
+		// Coalesce bursts of output into one redraw without blocking the writer
+		if logsQueued.Swap(true) {
+			return
+		}
+		go app.QueueUpdateDraw(func() {
+			logsQueued.Store(false)
+			statusLogs.SetText(serviceLogs.String()).ScrollToEnd()
+		})
+	}
+
+	statusLayout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(newPageHeader(app, statusTitle, board, clock), 3, 1, false).
+		AddItem(statusDescription, 2, 1, false).
+		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
+		AddItem(statusCommands, 5, 1, false).
+		AddItem(statusInput, 1, 1, true).
+		AddItem(tview.NewTextView().SetText(""), 1, 0, false).
+		AddItem(statusTable, 11, 0, false).
+		AddItem(statusLogs, 0, 1, false)
+
 	// PAGE ROUTES
 	pages := tview.NewPages().
 		AddPage("main", mainLayout, true, true).
 		AddPage("summary", summaryLayout, true, false).
 		AddPage("budget", budgetLayout, true, false).
 		AddPage("searchStocks", searchStocksLayout, true, false).
-		AddPage("searchCrypto", searchCryptoLayout, true, false)
+		AddPage("compare", compareLayout, true, false).
+		AddPage("watchlist", watchlistLayout, true, false).
+		AddPage("alerts", alertsLayout, true, false).
+		AddPage("searchCrypto", searchCryptoLayout, true, false).
+		AddPage("status", statusLayout, true, false)
 
 	// INPUTS
 	mainInput.SetDoneFunc(func(key tcell.Key) {
@@ -301,28 +1028,13 @@ Example: search bitcoin`)
 			cmd := mainInput.GetText()
 			switch cmd {
 			case "summary":
-				os.Remove("../sprint3/microservice-b/output_summary.json")
-				err := writeSummaryInput("../sprint3/microservice-b/input_summary.json")
-				if err != nil {
-					log.Printf("Error writing summary trigger: %v", err)
-				} else {
-					summaryWaiting.SetText("Waiting for data...")
-					indicesTable.Clear()
-					summaryLayout.RemoveItem(indicesTable)
-
-					waitForSummaryData(app, "../sprint3/microservice-b/output_summary.json", func(indices []IndexData) {
-						summaryLayout.AddItem(tview.NewTextView().SetText("Market Summary"), 1, 1, false)
-						summaryLayout.AddItem(indicesTable, 0, 1, false)
-						renderSummaryTable(indicesTable, indices)
-						summaryWaiting.SetText("")
-					})
-				}
+				summaryWaiting.SetText("Waiting for data...")
+				indicesTable.Clear()
+				chartLoaded = ""
+				refreshSummary(summaryPending.start())
 				pages.SwitchToPage("summary")
 				app.SetFocus(summaryInput)
 			case "budget":
-				err := os.Remove("../sprint3/microservice-a/output.json")
-				if err != nil {
-				}
 				pages.SwitchToPage("budget")
 			case "search-stocks":
 				pages.SwitchToPage("searchStocks")
@@ -330,6 +1042,19 @@ Example: search bitcoin`)
 			case "search-crypto":
 				pages.SwitchToPage("searchCrypto")
 				app.SetFocus(searchCryptoInput)
+			case "alerts":
+				renderAlertsTable(alertsTable, alertBook.Alerts)
+				pages.SwitchToPage("alerts")
+				app.SetFocus(alertsInput)
+			case "watchlist":
+				watchlistShown = true
+				restartWatchlist()
+				pages.SwitchToPage("watchlist")
+				app.SetFocus(watchlistInput)
+			case "status":
+				renderStatusTable(statusTable, board.snapshot(), board.interval)
+				pages.SwitchToPage("status")
+				app.SetFocus(statusInput)
 			case "quit":
 				PromptQuit(app, mainLayout, mainCommands, mainInput, mainCommandsText)
 			default:
@@ -338,11 +1063,57 @@ Example: search bitcoin`)
 		}
 	})
 
+	// updateSummaryIndices saves a new index list and refreshes straight away
+	updateSummaryIndices := func(indices []string) {
+		if len(indices) == 0 {
+			summaryWaiting.SetText("Keep at least one index on the summary")
+			return
+		}
+		summaryIndices = indices
+		if err := saveState(indicesPath, summaryIndices); err != nil {
+			summaryWaiting.SetText(fmt.Sprintf("Could not save index list: %v", err))
+		} else {
+			summaryWaiting.SetText("Waiting for data...")
+		}
+		refreshSummary(summaryPending.start())
+	}
+
 	summaryInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyTab {
+			app.SetFocus(summaryChart)
+			return
+		}
 		if key == tcell.KeyEnter {
-			cmd := summaryInput.GetText()
+			cmd := strings.TrimSpace(summaryInput.GetText())
+			fields := strings.Fields(cmd)
+			if len(fields) > 1 && strings.ToLower(fields[0]) == "add" {
+				updateSummaryIndices(normalizeTickers(append(slices.Clone(summaryIndices), fields[1:]...)))
+			} else if len(fields) > 1 && strings.ToLower(fields[0]) == "remove" {
+				removed := normalizeTickers(fields[1:])
+				updateSummaryIndices(slices.DeleteFunc(slices.Clone(summaryIndices), func(ticker string) bool {
+					return slices.Contains(removed, ticker)
+				}))
+			} else if len(fields) == 2 && strings.ToLower(fields[0]) == "chart" {
+				if ticker := strings.ToUpper(fields[1]); slices.Contains(summaryIndices, ticker) {
+					chartTicker = ticker
+					loadChart()
+				} else {
+					summaryWaiting.SetText(fmt.Sprintf("%s is not on the summary, add it first", ticker))
+				}
+			} else if len(fields) == 2 && strings.ToLower(fields[0]) == "range" {
+				if i, ok := findChartRange(fields[1]); ok {
+					chartRangeIndex = i
+					loadChart()
+				} else {
+					summaryWaiting.SetText("Ranges are 1W, 1M, 6M, 1Y and 5Y")
+				}
+			}
 			switch cmd {
+			case "reset":
+				updateSummaryIndices(cfg.SummaryIndices)
 			case "main":
+				summaryPending.stop()
+				chartPending.stop()
 				pages.SwitchToPage("main")
 				app.SetFocus(mainInput)
 			case "quit":
@@ -358,6 +1129,7 @@ Example: search bitcoin`)
 			cmd := budgetMainInput.GetText()
 			switch cmd {
 			case "main":
+				budgetPending.stop()
 				budgetMainInput.SetText("")
 				pages.SwitchToPage("main")
 				app.SetFocus(mainInput)
@@ -369,59 +1141,396 @@ Example: search bitcoin`)
 		summaryInput.SetText("")
 	})
 
-	searchStocksInput.SetDoneFunc(func(key tcell.Key) {
-		cmd := strings.TrimSpace(searchStocksInput.GetText())
-		if key == tcell.KeyEnter {
-			if strings.HasPrefix(strings.ToLower(cmd), "search $") {
-				searchStocksWaiting.SetText("Waiting for stock data...")
-				os.Remove("../sprint3/microservice-c/output_stock.json")
-				ticker := strings.TrimPrefix(cmd[7:], "$")
-				err := writeTickerInput("../sprint3/microservice-c/input_stock.json", ticker)
-				if err != nil {
-					searchStocksWaiting.SetText("Failed to write input.")
-				} else {
-					searchStocksTable.Clear()
-					searchStocksLayout.RemoveItem(searchStocksTable)
-					searchStocksLayout.AddItem(searchStocksTable, 0, 1, false)
-					showMore = false
-					go waitForStockDataAndRender(app, "../sprint3/microservice-c/output_stock.json", searchStocksTable, showMore, searchStocksWaiting)
-				}
-			} else if strings.ToLower(cmd) == "show-more" {
-				showMore = true
-				go waitForStockDataAndRender(app, "../sprint3/microservice-c/output_stock.json", searchStocksTable, showMore, searchStocksWaiting)
+	// searchStocks requests every ticker at once and fills in the table, in
+	// the order searched, as the quotes arrive. Problems with search terms
+	// that were never sent are listed along with the service's errors.
+	searchStocks := func(tickers []string, notes []string) {
+		searchStocksWaiting.SetText("Waiting for stock data...")
+		searchStocksTable.Clear()
+		indicatorPending.stop()
+		showMore = false
+		stockResults = nil
+		stockOrder = stockSort{Column: -1}
+
+		ctx := stockPending.start()
+		remaining := len(tickers)
+		failed := notes
+		var cached []string
+		var lastErr error
+		report := func() {
+			if len(stockResults) == 0 && remaining == 0 && len(tickers) == 1 {
+				renderErrorTable(searchStocksTable, "Failed to load stock data", lastErr)
+				fitStockTable()
+				searchStocksWaiting.SetText(fmt.Sprintf("Stock service error: %v", lastErr))
+				return
+			}
+			showStocks()
+			switch {
+			case remaining > 0:
+				searchStocksWaiting.SetText(fmt.Sprintf("Waiting for %d more...", remaining))
+			case len(failed) > 0 || len(cached) > 0:
+				searchStocksWaiting.SetText(strings.Join(append(slices.Clone(failed), cached...), "; "))
+			default:
 				searchStocksWaiting.SetText("")
 			}
 		}
-		switch cmd {
-		case "main":
-			pages.SwitchToPage("main")
-			app.SetFocus(mainInput)
-		case "quit":
-			PromptQuit(app, searchStocksLayout, searchStocksCommands, searchStocksInput, searchStocksCommandsText)
-		default:
+		for _, ticker := range tickers {
+			waitForStockData(ctx, app, services[serviceStock], ticker, cacheFirst, func(data StockData, age quoteAge) {
+				if label := age.label(time.Now()); label != "" {
+					cached = append(cached, fmt.Sprintf("%s %s", ticker, label))
+				} else {
+					checkAlerts(stockAlertQuote(data, time.Now()))
+				}
+				stockResults = append(stockResults, data)
+				slices.SortStableFunc(stockResults, func(a, b StockData) int {
+					return cmp.Compare(slices.Index(tickers, strings.ToUpper(a.Ticker)), slices.Index(tickers, strings.ToUpper(b.Ticker)))
+				})
+				remaining--
+				report()
+			}, func(err error) {
+				lastErr = err
+				failed = append(failed, fmt.Sprintf("%s: %s", ticker, symbols.didYouMean(ticker, err)))
+				remaining--
+				report()
+			}, func(err error) {
+				searchStocksWaiting.SetText(fmt.Sprintf("Stock service: %v, still waiting...", err))
+			})
 		}
-		searchStocksInput.SetText("")
-	})
+	}
 
-	searchCryptoInput.SetDoneFunc(func(key tcell.Key) {
-		cmd := searchCryptoInput.GetText()
+	searchStocksInput.SetDoneFunc(func(key tcell.Key) {
+		cmd := strings.TrimSpace(searchStocksInput.GetText())
+		fields := strings.Fields(cmd)
+		if key == tcell.KeyTab {
+			app.SetFocus(candles)
+			return
+		}
+		if key == tcell.KeyEnter && len(fields) > 0 {
+			switch strings.ToLower(fields[0]) {
+			case "search":
+				tickers, notes := symbols.resolveAll(fields[1:])
+				if len(tickers) > 0 {
+					searchStocks(tickers, notes)
+				} else if len(notes) > 0 {
+					searchStocksWaiting.SetText(strings.Join(notes, "; "))
+				}
+			case "sort":
+				if column, ok := findStockColumn(strings.Join(fields[1:], " ")); ok {
+					stockOrder = stockOrder.toggle(column)
+					if !stockColumns[column].Compact {
+						showMore = true
+						loadIndicators()
+					}
+					showStocks()
+				} else {
+					var names []string
+					for _, column := range stockColumns {
+						names = append(names, column.Name)
+					}
+					searchStocksWaiting.SetText("Sort by " + strings.Join(names, ", "))
+				}
+			case "show-more":
+				showMore = true
+				if len(stockResults) > 0 {
+					showStocks()
+					loadIndicators()
+				}
+			case "compare":
+				if len(stockResults) == 0 {
+					searchStocksWaiting.SetText("Search for the stocks to compare first")
+					break
+				}
+				if len(fields) > 1 {
+					i, ok := findChartRange(fields[1])
+					if !ok {
+						searchStocksWaiting.SetText("Ranges are 1W, 1M, 6M, 1Y and 5Y")
+						break
+					}
+					compareRangeIndex = i
+				}
+				compareTickers = nil
+				for _, stock := range stockResults {
+					compareTickers = append(compareTickers, stock.Ticker)
+				}
+				loadCompare()
+				pages.SwitchToPage("compare")
+				app.SetFocus(compareInput)
+			case "chart":
+				if len(fields) < 2 {
+					searchStocksWaiting.SetText("Usage: chart $TICKER [RANGE] [INTERVAL]")
+					break
+				}
+				ticker, note, err := symbols.resolve(fields[1])
+				if err != nil {
+					searchStocksWaiting.SetText(err.Error())
+					break
+				}
+				searchStocksWaiting.SetText(note)
+				rangeIndex, interval, valid := 2, "", true
+				for _, arg := range fields[2:] {
+					if i, ok := findChartRange(arg); ok {
+						rangeIndex = i
+					} else if slices.Contains(stockIntervals, strings.ToLower(arg)) {
+						interval = strings.ToLower(arg)
+					} else {
+						valid = false
+					}
+				}
+				if !valid {
+					searchStocksWaiting.SetText("Ranges are 1W, 1M, 6M, 1Y and 5Y; intervals are 1d, 1wk and 1mo")
+					break
+				}
+				if interval == "" {
+					interval = "1d"
+					if chartRanges[rangeIndex].Label == "5Y" {
+						interval = "1wk"
+					}
+				}
+				loadCandles(ticker, chartRanges[rangeIndex], interval)
+			case "watch", "unwatch":
+				if len(stockResults) == 0 {
+					searchStocksWaiting.SetText("Search for the stocks to watch first")
+					break
+				}
+				name := watch.Current
+				if len(fields) > 1 {
+					name = strings.ToLower(fields[1])
+				}
+				var tickers []string
+				for _, stock := range stockResults {
+					tickers = append(tickers, stock.Ticker)
+				}
+				var changed []string
+				if strings.ToLower(fields[0]) == "watch" {
+					changed = watch.add(name, tickers)
+					searchStocksWaiting.SetText(fmt.Sprintf("Added %d to watchlist %s", len(changed), name))
+				} else {
+					changed = watch.remove(name, tickers)
+					searchStocksWaiting.SetText(fmt.Sprintf("Removed %d from watchlist %s", len(changed), name))
+				}
+				if len(changed) > 0 {
+					saveWatchlists()
+					if name == watch.Current {
+						restartWatchlist()
+					}
+				}
+			}
+		}
+		switch cmd {
+		case "main":
+			stockPending.stop()
+			candlePending.stop()
+			indicatorPending.stop()
+			pages.SwitchToPage("main")
+			app.SetFocus(mainInput)
+		case "quit":
+			PromptQuit(app, searchStocksLayout, searchStocksCommands, searchStocksInput, searchStocksCommandsText)
+		default:
+		}
+		searchStocksInput.SetText("")
+	})
+
+	watchlistInput.SetDoneFunc(func(key tcell.Key) {
+		cmd := strings.TrimSpace(watchlistInput.GetText())
+		fields := strings.Fields(cmd)
+		if key == tcell.KeyEnter && len(fields) > 0 {
+			switch strings.ToLower(fields[0]) {
+			case "open":
+				if len(fields) == 2 {
+					watch.open(fields[1])
+					saveWatchlists()
+					restartWatchlist()
+				}
+			case "add":
+				tickers, notes := symbols.resolveAll(fields[1:])
+				if added := watch.add(watch.Current, tickers); len(added) > 0 {
+					saveWatchlists()
+					restartWatchlist()
+				}
+				if len(notes) > 0 {
+					watchlistWaiting.SetText(strings.Join(notes, "; "))
+				}
+			case "remove":
+				tickers, notes := symbols.resolveAll(fields[1:])
+				if removed := watch.remove(watch.Current, tickers); len(removed) > 0 {
+					saveWatchlists()
+					showWatchlist()
+				}
+				if len(notes) > 0 {
+					watchlistWaiting.SetText(strings.Join(notes, "; "))
+				}
+			case "delete":
+				if len(fields) == 2 {
+					if watch.delete(strings.ToLower(fields[1])) {
+						saveWatchlists()
+						restartWatchlist()
+					} else {
+						watchlistWaiting.SetText(fmt.Sprintf("There is no watchlist %s", fields[1]))
+					}
+				}
+			case "refresh":
+				watchlistWaiting.SetText("Refreshing...")
+				refreshWatchlist(watchPending.start())
+			}
+		}
+		switch cmd {
+		case "search":
+			leaveWatchlist()
+			pages.SwitchToPage("searchStocks")
+			app.SetFocus(searchStocksInput)
+		case "main":
+			leaveWatchlist()
+			pages.SwitchToPage("main")
+			app.SetFocus(mainInput)
+		case "quit":
+			PromptQuit(app, watchlistLayout, watchlistCommands, watchlistInput, watchlistCommandsText)
+		default:
+		}
+		watchlistInput.SetText("")
+	})
+
+	// changeAlerts applies a change to the alert book, saves it and shows the
+	// message the change returns
+	changeAlerts := func(change func() string) {
+		message := change()
+		alertBook.forget()
+		saveAlerts()
+		renderAlertsTable(alertsTable, alertBook.Alerts)
+		alertsMessage.SetText(message)
+	}
+	// changeAlertRule applies a change made by add or edit. A coin is first
+	// looked up with the crypto service, so that a rule on a coin the service
+	// does not know, or on a stock written like a coin, is not kept. The
+	// change runs once the lookup is done, against the list as it is then.
+	changeAlertRule := func(rule alertRule, change func() string) {
+		if rule.Kind != alertCrypto {
+			changeAlerts(change)
+			return
+		}
+		alertsMessage.SetText(fmt.Sprintf("Looking up %s...", rule.Symbol))
+		waitForCryptoData(alertPending.start(), app, services[serviceCrypto], rule.Symbol, func(_ []OrderedPair, _ quoteAge) {
+			changeAlerts(change)
+		}, func(err error) {
+			var serviceErr *ServiceError
+			if errors.As(err, &serviceErr) {
+				alertsMessage.SetText(fmt.Sprintf("%v; write stock tickers in capitals or with a $, e.g. $%s", err, strings.ToUpper(rule.Symbol)))
+				return
+			}
+			changeAlerts(func() string {
+				return fmt.Sprintf("%s, but %s could not be looked up: %v", change(), rule.Symbol, err)
+			})
+		}, func(err error) {
+			alertsMessage.SetText(fmt.Sprintf("Crypto service: %v, still waiting...", err))
+		})
+	}
+
+	alertsInput.SetDoneFunc(func(key tcell.Key) {
+		cmd := strings.TrimSpace(alertsInput.GetText())
+		fields := strings.Fields(cmd)
+		if key == tcell.KeyEnter && len(fields) > 0 {
+			// alert picks out alert N of "edit N", "remove N" and "rearm N"
+			alert := func() (int, bool) {
+				if len(fields) < 2 {
+					alertsMessage.SetText(fmt.Sprintf("Usage: %s N", fields[0]))
+					return 0, false
+				}
+				n, err := strconv.Atoi(fields[1])
+				if err != nil || n < 1 || n > len(alertBook.Alerts) {
+					alertsMessage.SetText(fmt.Sprintf("There is no alert %s", fields[1]))
+					return 0, false
+				}
+				return n - 1, true
+			}
+			switch strings.ToLower(fields[0]) {
+			case "add":
+				rule, err := parseAlertRule(strings.Join(fields[1:], " "))
+				if err != nil {
+					alertsMessage.SetText(err.Error())
+					break
+				}
+				changeAlertRule(rule, func() string {
+					alertBook.Alerts = append(alertBook.Alerts, rule)
+					return fmt.Sprintf("Added alert %d", len(alertBook.Alerts))
+				})
+			case "edit":
+				i, ok := alert()
+				if !ok {
+					break
+				}
+				rule, err := parseAlertRule(strings.Join(fields[2:], " "))
+				if err != nil {
+					alertsMessage.SetText(err.Error())
+					break
+				}
+				old := alertBook.Alerts[i]
+				changeAlertRule(rule, func() string {
+					// The list may have changed while the coin was looked up
+					if i >= len(alertBook.Alerts) || !alertBook.Alerts[i].sameAlert(old) {
+						return fmt.Sprintf("Alert %d changed while %s was looked up, edit it again", i+1, rule.Symbol)
+					}
+					alertBook.Alerts[i] = rule
+					return fmt.Sprintf("Changed alert %d", i+1)
+				})
+			case "remove":
+				if i, ok := alert(); ok {
+					changeAlerts(func() string {
+						alertBook.Alerts = slices.Delete(alertBook.Alerts, i, i+1)
+						return fmt.Sprintf("Removed alert %d", i+1)
+					})
+				}
+			case "rearm":
+				if i, ok := alert(); ok {
+					changeAlerts(func() string {
+						alertBook.Alerts[i].Triggered = time.Time{}
+						return fmt.Sprintf("Alert %d is armed", i+1)
+					})
+				}
+			case "main":
+				alertPending.stop()
+				pages.SwitchToPage("main")
+				app.SetFocus(mainInput)
+			case "quit":
+				PromptQuit(app, alertsLayout, alertsCommands, alertsInput, alertsCommandsText)
+			default:
+				alertsMessage.SetText(fmt.Sprintf("Unknown command %q", fields[0]))
+			}
+		}
+		alertsInput.SetText("")
+	})
+
+	searchCryptoInput.SetDoneFunc(func(key tcell.Key) {
+		cmd := searchCryptoInput.GetText()
 		if key == tcell.KeyEnter {
 			if strings.HasPrefix(cmd, "search ") {
 				searchCryptoWaiting.SetText("Waiting for cryptocurrency data...")
-				os.Remove("../sprint3/microservice-d/output_crypto.json")
 				coin := strings.TrimPrefix(cmd, "search ")
-				err := writeCryptoInput("../sprint3/microservice-d/input_crypto.json", coin)
-				if err != nil {
-					searchCryptoWaiting.SetText("Failed to write input.")
-				} else {
-					searchCryptoTable.Clear()
-					searchCryptoLayout.RemoveItem(searchCryptoTable)
-					searchCryptoLayout.AddItem(searchCryptoTable, 0, 1, false)
-					go waitForCryptoDataAndRender(app, "../sprint3/microservice-d/output_crypto.json", searchCryptoTable, searchCryptoWaiting)
-				}
+				searchCryptoTable.Clear()
+				searchCryptoLayout.RemoveItem(searchCryptoTable)
+				searchCryptoLayout.AddItem(searchCryptoTable, 0, 1, false)
+				waitForCryptoData(cryptoPending.start(), app, services[serviceCrypto], coin, func(prices []OrderedPair, age quoteAge) {
+					renderCryptoTable(searchCryptoTable, prices)
+					searchCryptoWaiting.SetText(age.label(time.Now()))
+					if age.Err != nil {
+						searchCryptoWaiting.SetText(fmt.Sprintf("Crypto service error: %v, %s", age.Err, age.label(time.Now())))
+					}
+					if age.Cached {
+						return
+					}
+					for _, pair := range prices {
+						if price, err := strconv.ParseFloat(fmt.Sprint(pair.Value), 64); err == nil {
+							checkAlerts(alertQuote{Kind: alertCrypto, Symbol: strings.ToLower(pair.Key), Values: map[string]float64{"price": price}, At: time.Now()})
+						}
+					}
+				}, func(err error) {
+					renderErrorTable(searchCryptoTable, "Failed to read crypto data", err)
+					searchCryptoWaiting.SetText(fmt.Sprintf("Crypto service error: %v", err))
+				}, func(err error) {
+					searchCryptoWaiting.SetText(fmt.Sprintf("Crypto service: %v, still waiting...", err))
+				})
 			}
 			switch cmd {
 			case "main":
+				cryptoPending.stop()
 				pages.SwitchToPage("main")
 				app.SetFocus(mainInput)
 			case "quit":
@@ -432,6 +1541,54 @@ Example: search bitcoin`)
 		}
 	})
 
+	compareInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyTab {
+			app.SetFocus(compareChart)
+			return
+		}
+		if key == tcell.KeyEnter {
+			cmd := strings.TrimSpace(compareInput.GetText())
+			fields := strings.Fields(cmd)
+			if len(fields) == 2 && strings.ToLower(fields[0]) == "range" {
+				if i, ok := findChartRange(fields[1]); ok {
+					compareRangeIndex = i
+					loadCompare()
+				} else {
+					compareWaiting.SetText("Ranges are 1W, 1M, 6M, 1Y and 5Y")
+				}
+			}
+			switch cmd {
+			case "search":
+				comparePending.stop()
+				pages.SwitchToPage("searchStocks")
+				app.SetFocus(searchStocksInput)
+			case "main":
+				comparePending.stop()
+				pages.SwitchToPage("main")
+				app.SetFocus(mainInput)
+			case "quit":
+				PromptQuit(app, compareLayout, compareCommands, compareInput, compareCommandsText)
+			default:
+			}
+		}
+		compareInput.SetText("")
+	})
+
+	statusInput.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter {
+			cmd := statusInput.GetText()
+			switch cmd {
+			case "main":
+				pages.SwitchToPage("main")
+				app.SetFocus(mainInput)
+			case "quit":
+				PromptQuit(app, statusLayout, statusCommands, statusInput, statusCommandsText)
+			default:
+			}
+		}
+		statusInput.SetText("")
+	})
+
 	if err := app.SetRoot(pages, true).EnableMouse(true).Run(); err != nil {
 		panic(err)
 	}
@@ -464,203 +1621,672 @@ func PromptQuit(app *tview.Application, layout *tview.Flex, commandsView *tview.
 	})
 }
 
+// renderErrorTable replaces a page's table with an error. Errors reported by
+// the microservice itself show their code; anything else shows fallback.
+func renderErrorTable(table *tview.Table, fallback string, err error) {
+	table.Clear()
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		table.SetCell(0, 0, tview.NewTableCell("Field").SetAttributes(tcell.AttrBold).SetSelectable(false))
+		table.SetCell(0, 1, tview.NewTableCell("Problem in "+validationErr.Schema).SetAttributes(tcell.AttrBold).SetSelectable(false))
+		for i, field := range validationErr.Fields {
+			table.SetCell(i+1, 0, tview.NewTableCell(field.Path).SetTextColor(tcell.ColorRed))
+			table.SetCell(i+1, 1, tview.NewTableCell(field.Message))
+		}
+		return
+	}
+
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) {
+		table.SetCell(0, 0, tview.NewTableCell("Error").SetAttributes(tcell.AttrBold))
+		table.SetCell(0, 1, tview.NewTableCell(serviceErr.Code).SetTextColor(tcell.ColorRed))
+		table.SetCell(1, 0, tview.NewTableCell("Message").SetAttributes(tcell.AttrBold))
+		table.SetCell(1, 1, tview.NewTableCell(serviceErr.Message))
+		return
+	}
+	table.SetCell(0, 0, tview.NewTableCell(fallback))
+}
+
 // SUMMARY FUNCTIONS
-func loadIndexDataFromFile(path string) ([]IndexData, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
+// The summary response wraps the index list so it can carry the request ID
+type summaryResponse struct {
+	RequestID     string      `json:"request_id"`
+	SchemaVersion int         `json:"schema_version"`
+	Indices       []IndexData `json:"indices"`
+}
+
+func decodeIndexData(data []byte) ([]IndexData, error) {
+	var response summaryResponse
+	if err := json.Unmarshal(data, &response); err != nil {
 		return nil, err
 	}
 
-	var indices []IndexData
-	if err := json.Unmarshal(data, &indices); err != nil {
+	return response.Indices, nil
+}
+
+func summaryRequest(id string, indices []string) ([]byte, error) {
+	data := map[string]interface{}{"request_id": id, "schema_version": schemaVersion, "summary": 1, "indices": indices}
+	return json.MarshalIndent(data, "", "  ")
+}
+
+func waitForSummaryData(ctx context.Context, app *tview.Application, svc *Service, indices []string, onLoaded func([]IndexData, quoteAge), onError func(error), onStale func(error)) {
+	build := func(id string) ([]byte, error) {
+		return summaryRequest(id, indices)
+	}
+	fetchCached(ctx, app, svc, serviceSummary, cacheFallback, build, decodeIndexData, onLoaded, onError, onStale)
+}
+
+type indexHistoryResponse struct {
+	RequestID     string      `json:"request_id"`
+	SchemaVersion int         `json:"schema_version"`
+	Ticker        string      `json:"ticker"`
+	Series        []IndexData `json:"series"`
+}
+
+func decodeIndexHistory(data []byte) ([]IndexData, error) {
+	var response indexHistoryResponse
+	if err := json.Unmarshal(data, &response); err != nil {
 		return nil, err
 	}
+	return response.Series, nil
+}
 
-	return indices, nil
+func indexHistoryRequest(id, ticker string, from, to time.Time) ([]byte, error) {
+	data := map[string]interface{}{
+		"request_id":     id,
+		"schema_version": schemaVersion,
+		"history":        ticker,
+		"from":           from.Format(dateLayout),
+		"to":             to.Format(dateLayout),
+	}
+	return json.MarshalIndent(data, "", "  ")
 }
 
-func writeSummaryInput(filePath string) error {
-	data := map[string]int{"summary": 1}
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
+func waitForIndexHistory(ctx context.Context, app *tview.Application, svc *Service, ticker string, from, to time.Time, onLoaded func([]IndexData, quoteAge), onError func(error), onStale func(error)) {
+	build := func(id string) ([]byte, error) {
+		return indexHistoryRequest(id, ticker, from, to)
 	}
-	defer file.Close()
+	fetchCached(ctx, app, svc, serviceSummary+".history", cacheFirst, build, decodeIndexHistory, onLoaded, onError, onStale)
+}
 
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
+// chartRange is one of the range toggles of the summary chart
+type chartRange struct {
+	Label               string
+	Years, Months, Days int
 }
 
-func waitForSummaryData(app *tview.Application, path string, onLoaded func([]IndexData)) {
-	go func() {
-		for {
-			if _, err := os.Stat(path); err == nil {
-				data, err := loadIndexDataFromFile(path)
-				if err == nil {
-					app.QueueUpdateDraw(func() {
-						onLoaded(data)
-					})
-					break
-				}
-			}
-			time.Sleep(1 * time.Second)
+var chartRanges = []chartRange{
+	{Label: "1W", Days: 7},
+	{Label: "1M", Months: 1},
+	{Label: "6M", Months: 6},
+	{Label: "1Y", Years: 1},
+	{Label: "5Y", Years: 5},
+}
+
+// start is the first day of the range ending on end
+func (r chartRange) start(end time.Time) time.Time {
+	return end.AddDate(-r.Years, -r.Months, -r.Days)
+}
+
+func findChartRange(label string) (int, bool) {
+	for i, r := range chartRanges {
+		if strings.EqualFold(r.Label, label) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func renderChartRanges(view *tview.TextView, ticker string, selected int) {
+	text := ticker + "  "
+	for i, r := range chartRanges {
+		if i == selected {
+			text += fmt.Sprintf("[::r] %s [::-] ", r.Label)
+		} else {
+			text += fmt.Sprintf(" %s  ", r.Label)
 		}
-	}()
+	}
+	view.SetText(text)
+}
+
+func closingPrices(series []IndexData) []chartPoint {
+	points := make([]chartPoint, len(series))
+	for i, day := range series {
+		points[i] = chartPoint{Label: day.Date, Value: day.Close}
+	}
+	return points
+}
+
+// normalizeTickers upper-cases tickers and drops blanks and duplicates
+func normalizeTickers(tickers []string) []string {
+	var normalized []string
+	for _, ticker := range tickers {
+		ticker = strings.ToUpper(strings.TrimSpace(ticker))
+		if ticker != "" && !slices.Contains(normalized, ticker) {
+			normalized = append(normalized, ticker)
+		}
+	}
+	return normalized
+}
+
+// loadSummaryIndices returns the saved index list, or fallback if none has
+// been saved yet or it holds no tickers.
+func loadSummaryIndices(path string, fallback []string) ([]string, error) {
+	var saved []string
+	ok, err := loadState(path, &saved)
+	if saved = normalizeTickers(saved); err != nil || !ok || len(saved) == 0 {
+		return fallback, err
+	}
+	return saved, nil
 }
 
-func renderSummaryTable(summaryTable *tview.Table, indices []IndexData) {
+// missingIndices lists the requested tickers the service sent no data for
+func missingIndices(requested []string, indices []IndexData) []string {
+	var missing []string
+	for _, ticker := range requested {
+		found := slices.ContainsFunc(indices, func(index IndexData) bool {
+			return strings.EqualFold(index.Ticker, ticker)
+		})
+		if !found {
+			missing = append(missing, ticker)
+		}
+	}
+	return missing
+}
+
+// summaryRow is an index with its change from the previous close and the
+// table columns whose value changed since the last refresh. FromOpen is set
+// while no previous close is known and the change is from the day's open.
+type summaryRow struct {
+	IndexData
+	Change   float64
+	Percent  float64
+	FromOpen bool
+	Session  session
+	Changed  map[int]bool
+}
+
+// summaryTracker remembers the indices last shown so every refresh can be
+// compared with them. The previous close is the service's PrevClose when it
+// sends one, otherwise the last close we saw on an earlier date, otherwise
+// the day's open.
+type summaryTracker struct {
+	last      map[string]IndexData
+	prevClose map[string]float64
+}
+
+func newSummaryTracker() *summaryTracker {
+	return &summaryTracker{last: make(map[string]IndexData), prevClose: make(map[string]float64)}
+}
+
+func (t *summaryTracker) empty() bool {
+	return len(t.last) == 0
+}
+
+func (t *summaryTracker) update(indices []IndexData, now time.Time) []summaryRow {
+	rows := make([]summaryRow, 0, len(indices))
+	for _, index := range indices {
+		previous, seen := t.last[index.Ticker]
+		if seen && previous.Date != index.Date {
+			t.prevClose[index.Ticker] = previous.Close
+		}
+
+		basis, fromOpen := index.Open, false
+		if index.PrevClose > 0 {
+			basis = index.PrevClose
+		} else if close, ok := t.prevClose[index.Ticker]; ok {
+			basis = close
+		} else {
+			fromOpen = true
+		}
+
+		row := summaryRow{
+			IndexData: index,
+			Change:    index.Close - basis,
+			FromOpen:  fromOpen,
+			Session:   usMarket.quoteSession(index.Date, now),
+			Changed:   make(map[int]bool),
+		}
+		if basis != 0 {
+			row.Percent = row.Change / basis * 100
+		}
+		if seen {
+			row.Changed[2] = previous.Date != index.Date
+			row.Changed[3] = previous.Open != index.Open
+			row.Changed[4] = previous.High != index.High
+			row.Changed[5] = previous.Low != index.Low
+			row.Changed[6] = previous.Close != index.Close
+			row.Changed[7] = row.Changed[6]
+			row.Changed[8] = row.Changed[6]
+			row.Changed[9] = previous.Volume != index.Volume
+		}
+
+		t.last[index.Ticker] = index
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func renderSummaryTable(summaryTable *tview.Table, rows []summaryRow, flash bool) {
 	summaryTable.Clear()
 
-	headers := []string{"Name", "Ticker", "Date", "Open", "High", "Low", "Close", "Volume"}
+	headers := []string{"Name", "Ticker", "Date", "Open", "High", "Low", "Close", "Change", "Change %", "Volume", "Session"}
 	for col, h := range headers {
 		summaryTable.SetCell(0, col, tview.NewTableCell(h).SetAlign(tview.AlignCenter).SetSelectable(false))
 	}
 
-	for row, index := range indices {
-		summaryTable.SetCell(row+1, 0, tview.NewTableCell(index.Name))
-		summaryTable.SetCell(row+1, 1, tview.NewTableCell(index.Ticker))
-		summaryTable.SetCell(row+1, 2, tview.NewTableCell(index.Date))
-		summaryTable.SetCell(row+1, 3, tview.NewTableCell(fmt.Sprintf("%.2f", index.Open)).SetAlign(tview.AlignRight))
-		summaryTable.SetCell(row+1, 4, tview.NewTableCell(fmt.Sprintf("%.2f", index.High)).SetAlign(tview.AlignRight))
-		summaryTable.SetCell(row+1, 5, tview.NewTableCell(fmt.Sprintf("%.2f", index.Low)).SetAlign(tview.AlignRight))
-		summaryTable.SetCell(row+1, 6, tview.NewTableCell(fmt.Sprintf("%.2f", index.Close)).SetAlign(tview.AlignRight))
-		summaryTable.SetCell(row+1, 7, tview.NewTableCell(fmt.Sprintf("%d", index.Volume)).SetAlign(tview.AlignRight))
+	for i, index := range rows {
+		row := i + 1
+		summaryTable.SetCell(row, 0, tview.NewTableCell(index.Name))
+		summaryTable.SetCell(row, 1, tview.NewTableCell(index.Ticker))
+		summaryTable.SetCell(row, 2, tview.NewTableCell(index.Date))
+		summaryTable.SetCell(row, 3, tview.NewTableCell(fmt.Sprintf("%.2f", index.Open)).SetAlign(tview.AlignRight))
+		summaryTable.SetCell(row, 4, tview.NewTableCell(fmt.Sprintf("%.2f", index.High)).SetAlign(tview.AlignRight))
+		summaryTable.SetCell(row, 5, tview.NewTableCell(fmt.Sprintf("%.2f", index.Low)).SetAlign(tview.AlignRight))
+		summaryTable.SetCell(row, 6, tview.NewTableCell(fmt.Sprintf("%.2f", index.Close)).SetAlign(tview.AlignRight))
+		change, percent := fmt.Sprintf("%+.2f", index.Change), fmt.Sprintf("%+.2f%%", index.Percent)
+		if index.FromOpen {
+			change += " vs open"
+		}
+		summaryTable.SetCell(row, 7, tview.NewTableCell(change).SetAlign(tview.AlignRight))
+		summaryTable.SetCell(row, 8, tview.NewTableCell(percent).SetAlign(tview.AlignRight))
+		summaryTable.SetCell(row, 9, tview.NewTableCell(fmt.Sprintf("%d", index.Volume)).SetAlign(tview.AlignRight))
+		summaryTable.SetCell(row, 10, tview.NewTableCell(index.Session.String()))
+
+		// Color rows by direction and highlight cells that just changed
+		color := tview.Styles.PrimaryTextColor
+		if index.Change > 0 {
+			color = tcell.ColorGreen
+		} else if index.Change < 0 {
+			color = tcell.ColorRed
+		}
+		for col := range headers[:len(headers)-1] {
+			cell := summaryTable.GetCell(row, col).SetTextColor(color)
+			if flash && index.Changed[col] {
+				cell.SetBackgroundColor(tcell.ColorYellow).SetTextColor(tcell.ColorBlack)
+			}
+		}
 	}
 }
 
 // STOCK FUNCTIONS
-func loadStockFromFile(path string) (StockData, error) {
-	var data StockData
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		return data, err
+func decodeStockData(data []byte) (StockData, error) {
+	var stock StockData
+	err := json.Unmarshal(data, &stock)
+	return stock, err
+}
+
+func tickerRequest(id string, ticker string) ([]byte, error) {
+	input := map[string]interface{}{"request_id": id, "schema_version": schemaVersion, "ticker": ticker}
+	return json.MarshalIndent(input, "", "  ")
+}
+
+func waitForStockData(ctx context.Context, app *tview.Application, svc *Service, ticker string, policy cachePolicy, onLoaded func(StockData, quoteAge), onError func(error), onStale func(error)) {
+	build := func(id string) ([]byte, error) {
+		return tickerRequest(id, ticker)
 	}
-	err = json.Unmarshal(bytes, &data)
-	return data, err
+	fetchCached(ctx, app, svc, serviceStock, policy, build, decodeStockData, onLoaded, onError, onStale)
 }
 
-func writeTickerInput(filePath string, ticker string) error {
-	input := map[string]string{"ticker": ticker}
-	data, err := json.MarshalIndent(input, "", "  ")
-	if err != nil {
-		return err
+// StockBar is one day of a stock's price history
+type StockBar struct {
+	Date   string
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume int64
+}
+
+type stockHistoryResponse struct {
+	RequestID     string     `json:"request_id"`
+	SchemaVersion int        `json:"schema_version"`
+	Ticker        string     `json:"ticker"`
+	Series        []StockBar `json:"series"`
+}
+
+func decodeStockHistory(data []byte) ([]StockBar, error) {
+	var response stockHistoryResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
 	}
+	return response.Series, nil
+}
 
-	return os.WriteFile(filePath, data, 0644)
+// stockIntervals are the bar lengths the stock service can be asked for
+var stockIntervals = []string{"1d", "1wk", "1mo"}
+
+func stockHistoryRequest(id, ticker string, from, to time.Time, interval string) ([]byte, error) {
+	data := map[string]interface{}{
+		"request_id":     id,
+		"schema_version": schemaVersion,
+		"history":        ticker,
+		"from":           from.Format(dateLayout),
+		"to":             to.Format(dateLayout),
+		"interval":       interval,
+	}
+	return json.MarshalIndent(data, "", "  ")
 }
 
-func waitForStockDataAndRender(app *tview.Application, path string, stockTable *tview.Table, showMore bool, message *tview.TextView) {
-	for {
-		if _, err := os.Stat(path); err == nil {
-			data, err := loadStockFromFile(path)
-			if err != nil {
-				log.Printf("Failed to parse stock data: %v", err)
-				return
-			}
+func waitForStockHistory(ctx context.Context, app *tview.Application, svc *Service, ticker string, from, to time.Time, interval string, onLoaded func([]StockBar, quoteAge), onError func(error), onStale func(error)) {
+	build := func(id string) ([]byte, error) {
+		return stockHistoryRequest(id, ticker, from, to, interval)
+	}
+	fetchCached(ctx, app, svc, serviceStock+".history", cacheFirst, build, decodeStockHistory, onLoaded, onError, onStale)
+}
 
-			app.QueueUpdateDraw(func() {
-				renderStockTable(stockTable, data, showMore)
-				message.SetText("")
-			})
+// stockColumn is a column of the stock comparison table. Compact columns are
+// shown before show-more.
+type stockColumn struct {
+	Name    string
+	Compact bool
+	Numeric bool
+	Value   func(stock StockData) float64
+	Text    func(stock StockData) string
+}
 
-			break
-		}
-		time.Sleep(500 * time.Millisecond)
+func stockChange(stock StockData) float64 {
+	return stock.Close - stock.Open
+}
+
+func stockChangePercent(stock StockData) float64 {
+	if stock.Open == 0 {
+		return 0
 	}
+	return stockChange(stock) / stock.Open * 100
 }
 
-func renderStockTable(stockTable *tview.Table, stockData StockData, showMore bool) {
-	stockTable.Clear()
+var stockColumns = []stockColumn{
+	{Name: "Ticker", Compact: true, Text: func(s StockData) string { return s.Ticker }},
+	{Name: "Date", Compact: true, Text: func(s StockData) string { return s.Date }},
+	{Name: "Open", Numeric: true, Value: func(s StockData) float64 { return s.Open }},
+	{Name: "High", Numeric: true, Value: func(s StockData) float64 { return s.High }},
+	{Name: "Low", Numeric: true, Value: func(s StockData) float64 { return s.Low }},
+	{Name: "Close", Compact: true, Numeric: true, Value: func(s StockData) float64 { return s.Close }},
+	{Name: "Change", Numeric: true, Value: stockChange},
+	{Name: "Change %", Compact: true, Numeric: true, Value: stockChangePercent},
+	{Name: "Volume", Numeric: true, Value: func(s StockData) float64 { return float64(s.Volume) }},
+	{Name: "SMA 20", Numeric: true, Value: indicatorValue(func(i *stockIndicators) float64 { return i.SMA })},
+	{Name: "EMA 20", Numeric: true, Value: indicatorValue(func(i *stockIndicators) float64 { return i.EMA })},
+	{Name: "RSI 14", Numeric: true, Value: indicatorValue(func(i *stockIndicators) float64 { return i.RSI })},
+	{Name: "MACD", Numeric: true, Value: indicatorValue(func(i *stockIndicators) float64 { return i.MACD })},
+	{Name: "BB %B", Numeric: true, Value: indicatorValue(func(i *stockIndicators) float64 { return i.PercentB })},
+	{Name: "ATR 14", Numeric: true, Value: indicatorValue(func(i *stockIndicators) float64 { return i.ATR })},
+	{Name: "Session", Compact: true, Text: func(s StockData) string { return usMarket.quoteSession(s.Date, time.Now()).String() }},
+}
 
-	if showMore {
-		// Full view
-		headers := []string{"Ticker", "Date", "Open", "High", "Low", "Close"}
+func (c stockColumn) format(stock StockData) string {
+	switch {
+	case !c.Numeric:
+		return c.Text(stock)
+	case math.IsNaN(c.Value(stock)):
+		return "-"
+	case strings.HasPrefix(c.Name, "Change"):
+		if strings.HasSuffix(c.Name, "%") {
+			return fmt.Sprintf("%+.2f%%", c.Value(stock))
+		}
+		return fmt.Sprintf("%+.2f", c.Value(stock))
+	case c.Name == "Volume":
+		return formatVolume(stock.Volume)
+	}
+	return fmt.Sprintf("%.2f", c.Value(stock))
+}
 
-		for col, h := range headers {
-			stockTable.SetCell(0, col,
-				tview.NewTableCell(h).
-					SetAlign(tview.AlignCenter).
-					SetSelectable(false))
+// findStockColumn matches a column name typed by the user, ignoring case,
+// spaces and a trailing "%" written as "pct"
+func findStockColumn(name string) (int, bool) {
+	key := func(s string) string {
+		s = strings.ToLower(strings.ReplaceAll(s, " ", ""))
+		return strings.Replace(s, "pct", "%", 1)
+	}
+	for i, column := range stockColumns {
+		if key(column.Name) == key(name) {
+			return i, true
 		}
+	}
+	return 0, false
+}
 
-		stockTable.SetCell(1, 0, tview.NewTableCell(stockData.Ticker))
-		stockTable.SetCell(1, 1, tview.NewTableCell(stockData.Date))
-		stockTable.SetCell(1, 2, tview.NewTableCell(fmt.Sprintf("%.2f", stockData.Open)).SetAlign(tview.AlignRight))
-		stockTable.SetCell(1, 3, tview.NewTableCell(fmt.Sprintf("%.2f", stockData.High)).SetAlign(tview.AlignRight))
-		stockTable.SetCell(1, 4, tview.NewTableCell(fmt.Sprintf("%.2f", stockData.Low)).SetAlign(tview.AlignRight))
-		stockTable.SetCell(1, 5, tview.NewTableCell(fmt.Sprintf("%.2f", stockData.Close)).SetAlign(tview.AlignRight))
-	} else {
-		// Compact view
-		headers := []string{"Ticker", "Date", "Close"}
+// stockSort is the column the stock table is sorted by; -1 keeps the order
+// the tickers were searched in.
+type stockSort struct {
+	Column     int
+	Descending bool
+}
 
-		for col, h := range headers {
-			stockTable.SetCell(0, col,
-				tview.NewTableCell(h).
-					SetAlign(tview.AlignCenter).
-					SetSelectable(false))
+// toggle sorts by column, reversing the order if it is sorted by it already.
+// Prices sort highest first and text A to Z.
+func (s stockSort) toggle(column int) stockSort {
+	if s.Column == column {
+		return stockSort{Column: column, Descending: !s.Descending}
+	}
+	return stockSort{Column: column, Descending: stockColumns[column].Numeric}
+}
+
+// stockIndicators are the latest values of a stock's technical indicators.
+// PercentB is where the close sits between the Bollinger Bands, 0 at the
+// lower band and 1 at the upper one.
+type stockIndicators struct {
+	SMA, EMA, RSI, MACD, PercentB, ATR float64
+}
+
+// indicatorHistory is how much daily history the indicators are worked out
+// from, enough for MACD's signal line
+var indicatorHistory = chartRange{Label: "6M", Months: 6}
+
+func latestIndicators(bars []StockBar) *stockIndicators {
+	closes := barValues(bars, closeOf)
+	macd, _, _ := indicators.MACD(closes, 12, 26, 9)
+	_, upper, lower := indicators.Bollinger(closes, 20, 2)
+	percentB := math.NaN()
+	if u, l := indicators.Last(upper), indicators.Last(lower); u > l {
+		percentB = (indicators.Last(closes) - l) / (u - l)
+	}
+	return &stockIndicators{
+		SMA:      indicators.Last(indicators.SMA(closes, 20)),
+		EMA:      indicators.Last(indicators.EMA(closes, 20)),
+		RSI:      indicators.Last(indicators.RSI(closes, 14)),
+		MACD:     indicators.Last(macd),
+		PercentB: percentB,
+		ATR:      indicators.Last(indicators.ATR(barValues(bars, highOf), barValues(bars, lowOf), closes, 14)),
+	}
+}
+
+// indicatorValue reads one indicator of a stock, NaN until they are loaded
+func indicatorValue(value func(*stockIndicators) float64) func(StockData) float64 {
+	return func(s StockData) float64 {
+		if s.Indicators == nil {
+			return math.NaN()
 		}
+		return value(s.Indicators)
+	}
+}
 
-		stockTable.SetCell(1, 0, tview.NewTableCell(stockData.Ticker))
-		stockTable.SetCell(1, 1, tview.NewTableCell(stockData.Date))
-		stockTable.SetCell(1, 2, tview.NewTableCell(fmt.Sprintf("%.2f", stockData.Close)).SetAlign(tview.AlignRight))
+func sortStocks(stocks []StockData, order stockSort) []StockData {
+	sorted := slices.Clone(stocks)
+	if order.Column < 0 {
+		return sorted
 	}
+	column := stockColumns[order.Column]
+	slices.SortStableFunc(sorted, func(a, b StockData) int {
+		var c int
+		if column.Numeric {
+			c = cmp.Compare(column.Value(a), column.Value(b))
+		} else {
+			c = strings.Compare(column.Text(a), column.Text(b))
+		}
+		if order.Descending {
+			return -c
+		}
+		return c
+	})
+	return sorted
 }
 
-// CRYPTO FUNCTIONS
-func writeCryptoInput(path string, coin string) error {
-	data := map[string]string{"coin": coin}
-	jsonData, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return err
+func renderStockTable(stockTable *tview.Table, stocks []StockData, showMore bool, order stockSort) {
+	stockTable.Clear()
+
+	col := 0
+	for i, column := range stockColumns {
+		if !showMore && !column.Compact {
+			continue
+		}
+		header := column.Name
+		if i == order.Column && order.Descending {
+			header += " ▼"
+		} else if i == order.Column {
+			header += " ▲"
+		}
+		stockTable.SetCell(0, col,
+			tview.NewTableCell(header).
+				SetAlign(tview.AlignCenter).
+				SetSelectable(false))
+
+		for row, stock := range sortStocks(stocks, order) {
+			cell := tview.NewTableCell(column.format(stock))
+			if column.Numeric {
+				cell.SetAlign(tview.AlignRight)
+			}
+			if strings.HasPrefix(column.Name, "Change") {
+				if stockChange(stock) > 0 {
+					cell.SetTextColor(tcell.ColorGreen)
+				} else if stockChange(stock) < 0 {
+					cell.SetTextColor(tcell.ColorRed)
+				}
+			}
+			stockTable.SetCell(row+1, col, cell)
+		}
+		col++
 	}
-	return os.WriteFile(path, jsonData, 0644)
 }
 
-type OrderedPair struct {
-	Key   string
-	Value interface{}
+// COMPARE FUNCTIONS
+
+var compareColors = []tcell.Color{
+	tcell.ColorAqua, tcell.ColorYellow, tcell.ColorFuchsia, tcell.ColorOrange,
+	tcell.ColorLime, tcell.ColorLightSkyBlue, tcell.ColorPink, tcell.ColorWhite,
 }
 
-func waitForCryptoDataAndRender(app *tview.Application, path string, table *tview.Table, message *tview.TextView) {
-	for {
-		if _, err := os.Stat(path); err == nil {
-			break
+// compareResult is the performance of one stock over the compared period
+type compareResult struct {
+	Ticker     string
+	From, To   string
+	Start, End float64
+	Return     float64
+}
+
+// rebaseHistories lines up the closes of every stock on the dates they all
+// have and expresses them as the percentage change since the first of those
+// dates, so stocks of any price can share one chart.
+func rebaseHistories(tickers []string, histories map[string][]StockBar) ([]chartSeries, []compareResult) {
+	if len(tickers) == 0 {
+		return nil, nil
+	}
+	counts := make(map[string]int)
+	for _, ticker := range tickers {
+		for _, bar := range histories[ticker] {
+			counts[bar.Date]++
+		}
+	}
+	var common []string
+	for _, bar := range histories[tickers[0]] {
+		if counts[bar.Date] == len(tickers) {
+			common = append(common, bar.Date)
 		}
-		time.Sleep(500 * time.Millisecond)
+	}
+	if len(common) == 0 {
+		return nil, nil
 	}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		app.QueueUpdateDraw(func() {
-			table.Clear()
-			table.SetCell(0, 0, tview.NewTableCell("Failed to read crypto data"))
+	var series []chartSeries
+	var results []compareResult
+	for i, ticker := range tickers {
+		closes := make(map[string]float64)
+		for _, bar := range histories[ticker] {
+			closes[bar.Date] = bar.Close
+		}
+		base := closes[common[0]]
+		points := make([]chartPoint, len(common))
+		for j, date := range common {
+			points[j] = chartPoint{Label: date, Value: (closes[date]/base - 1) * 100}
+		}
+		series = append(series, chartSeries{Name: ticker, Color: compareColors[i%len(compareColors)], Points: points})
+		results = append(results, compareResult{
+			Ticker: ticker,
+			From:   common[0],
+			To:     common[len(common)-1],
+			Start:  base,
+			End:    closes[common[len(common)-1]],
+			Return: points[len(points)-1].Value,
 		})
-		return
 	}
+	return series, results
+}
+
+func renderCompareTable(table *tview.Table, results []compareResult, colors map[string]tcell.Color) {
+	table.Clear()
+
+	headers := []string{"Ticker", "From", "To", "Start", "End", "Return"}
+	for col, h := range headers {
+		table.SetCell(0, col, tview.NewTableCell(h).SetAlign(tview.AlignCenter).SetSelectable(false))
+	}
+
+	// Best performer first
+	results = slices.Clone(results)
+	slices.SortStableFunc(results, func(a, b compareResult) int {
+		return cmp.Compare(b.Return, a.Return)
+	})
+	for i, result := range results {
+		returnColor := tcell.ColorGreen
+		if result.Return < 0 {
+			returnColor = tcell.ColorRed
+		}
+		table.SetCell(i+1, 0, tview.NewTableCell(result.Ticker).SetTextColor(colors[result.Ticker]))
+		table.SetCell(i+1, 1, tview.NewTableCell(result.From))
+		table.SetCell(i+1, 2, tview.NewTableCell(result.To))
+		table.SetCell(i+1, 3, tview.NewTableCell(fmt.Sprintf("%.2f", result.Start)).SetAlign(tview.AlignRight))
+		table.SetCell(i+1, 4, tview.NewTableCell(fmt.Sprintf("%.2f", result.End)).SetAlign(tview.AlignRight))
+		table.SetCell(i+1, 5, tview.NewTableCell(fmt.Sprintf("%+.2f%%", result.Return)).SetAlign(tview.AlignRight).SetTextColor(returnColor))
+	}
+}
+
+// CRYPTO FUNCTIONS
+func cryptoRequest(id string, coin string) ([]byte, error) {
+	data := map[string]interface{}{"request_id": id, "schema_version": schemaVersion, "coin": coin}
+	return json.MarshalIndent(data, "", "  ")
+}
+
+type OrderedPair struct {
+	Key   string
+	Value interface{}
+}
 
+func decodeCryptoData(data []byte) ([]OrderedPair, error) {
 	// Use a decoder to preserve order
 	var raw map[string]interface{}
 	decoder := json.NewDecoder(bytes.NewReader(data))
 	decoder.UseNumber()
 	if err := decoder.Decode(&raw); err != nil {
-		app.QueueUpdateDraw(func() {
-			table.Clear()
-			table.SetCell(0, 0, tview.NewTableCell("Invalid crypto JSON"))
-		})
-		return
+		return nil, fmt.Errorf("invalid crypto JSON: %w", err)
 	}
 
 	// Convert to ordered pairs
 	var ordered []OrderedPair
 	for k, v := range raw {
+		if k == "request_id" || k == "schema_version" {
+			continue
+		}
 		ordered = append(ordered, OrderedPair{Key: k, Value: v})
 	}
+	return ordered, nil
+}
 
-	app.QueueUpdateDraw(func() {
-		renderCryptoTable(table, ordered)
-		message.SetText("")
-	})
+func waitForCryptoData(ctx context.Context, app *tview.Application, svc *Service, coin string, onLoaded func([]OrderedPair, quoteAge), onError func(error), onStale func(error)) {
+	build := func(id string) ([]byte, error) {
+		return cryptoRequest(id, coin)
+	}
+	fetchCached(ctx, app, svc, serviceCrypto, cacheFirst, build, decodeCryptoData, onLoaded, onError, onStale)
 }
 
 func renderCryptoTable(table *tview.Table, data []OrderedPair) {
@@ -685,15 +2311,28 @@ func renderCryptoTable(table *tview.Table, data []OrderedPair) {
 }
 
 // BUDGET FUNCTIONS
-func saveBudgetToFile(filename string, total int, categories map[string]int) error {
-	// Build JSON manually: start with total
+
+// reservedBudgetKeys share the budget request's object with the categories,
+// so they cannot be category names
+var reservedBudgetKeys = []string{"request_id", "schema_version", "total"}
+
+func encodeBudget(id string, total int, categories map[string]int) ([]byte, error) {
+	for k := range categories {
+		if slices.Contains(reservedBudgetKeys, k) {
+			return nil, fmt.Errorf("%q is reserved and cannot be a budget category", k)
+		}
+	}
+
+	// Build JSON manually: start with request ID, schema version and total
 	builder := make(map[string]interface{})
+	builder["request_id"] = id
+	builder["schema_version"] = schemaVersion
 	builder["total"] = total
 
 	// Serialize separately
 	totalJSON, err := json.MarshalIndent(builder, "", "  ")
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Remove the final } so we can append to it
@@ -704,7 +2343,7 @@ func saveBudgetToFile(filename string, total int, categories map[string]int) err
 	for k, v := range categories {
 		catEntry, err := json.MarshalIndent(map[string]int{k: v}, "", "  ")
 		if err != nil {
-			return err
+			return nil, err
 		}
 		// Trim opening { and newline
 		line := string(catEntry[2 : len(catEntry)-2])
@@ -713,36 +2352,34 @@ func saveBudgetToFile(filename string, total int, categories map[string]int) err
 
 	final := totalStr + categoryJSON + "\n}\n"
 
-	return os.WriteFile(filename, []byte(final), 0644)
+	return []byte(final), nil
 }
 
-func waitForBudgetOutput(app *tview.Application, path string, table *tview.Table) {
-	for {
-		if _, err := os.Stat(path); err == nil {
-			break
-		}
-		time.Sleep(1 * time.Second)
+func decodeBudget(data []byte) (map[string]int, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON in budget output: %w", err)
 	}
 
-	data, err := os.ReadFile(path)
-	if err != nil {
-		app.QueueUpdateDraw(func() {
-			table.SetCell(0, 0, tview.NewTableCell("Failed to read output file"))
-		})
-		return
+	budget := make(map[string]int)
+	for k, v := range raw {
+		if k == "request_id" || k == "schema_version" {
+			continue
+		}
+		var amount int
+		if err := json.Unmarshal(v, &amount); err != nil {
+			return nil, fmt.Errorf("invalid amount for %q in budget output: %w", k, err)
+		}
+		budget[k] = amount
 	}
+	return budget, nil
+}
 
-	var budget map[string]int
-	if err := json.Unmarshal(data, &budget); err != nil {
-		app.QueueUpdateDraw(func() {
-			table.SetCell(0, 0, tview.NewTableCell("Invalid JSON in output file"))
-		})
-		return
+func waitForBudgetOutput(ctx context.Context, app *tview.Application, svc *Service, total int, categories map[string]int, onLoaded func(map[string]int), onError func(error), onStale func(error)) {
+	build := func(id string) ([]byte, error) {
+		return encodeBudget(id, total, categories)
 	}
-
-	app.QueueUpdateDraw(func() {
-		renderBudgetTable(table, budget)
-	})
+	fetch(ctx, app, svc, build, decodeBudget, onLoaded, onError, onStale)
 }
 
 func renderBudgetTable(table *tview.Table, budget map[string]int) {
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// RECORD AND REPLAY
+// A session archive is a JSON Lines file holding one RecordedExchange per
+// request sent to a microservice, in the order they completed.
+
+type RecordedExchange struct {
+	Service  string          `json:"service"`
+	Time     time.Time       `json:"time"`
+	Latency  Duration        `json:"latency"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    string          `json:"error,omitempty"`
+	// ErrorKind and ErrorDetail keep the type of the transport's error, so
+	// that replay fails the same way: retried or not, and reported the same.
+	// Responses that fail validation are recorded as they came and fail it
+	// again on replay.
+	ErrorKind   string          `json:"error_kind,omitempty"`
+	ErrorDetail json.RawMessage `json:"error_detail,omitempty"`
+}
+
+// Kinds of recorded errors
+const (
+	recordedServiceError  = "service"
+	recordedStaleResponse = "stale"
+	recordedTimeout       = "timeout"
+)
+
+// recordError stores err in the exchange along with its kind and, for the
+// typed errors, their fields
+func (e *RecordedExchange) recordError(err error) {
+	e.Error = err.Error()
+	var (
+		serviceErr *ServiceError
+		staleErr   *StaleResponseError
+		detail     interface{}
+	)
+	switch {
+	case errors.As(err, &serviceErr):
+		e.ErrorKind, detail = recordedServiceError, serviceErr
+	case errors.As(err, &staleErr):
+		e.ErrorKind, detail = recordedStaleResponse, staleErr
+	case errors.Is(err, context.DeadlineExceeded):
+		e.ErrorKind = recordedTimeout
+	}
+	if detail != nil {
+		e.ErrorDetail, _ = json.Marshal(detail)
+	}
+}
+
+// replayError rebuilds the recorded error for the request with the given ID.
+// Errors recorded without a kind come back as plain errors.
+func (e RecordedExchange) replayError(id string) error {
+	switch e.ErrorKind {
+	case recordedServiceError:
+		serviceErr := &ServiceError{}
+		if json.Unmarshal(e.ErrorDetail, serviceErr) == nil {
+			return serviceErr
+		}
+	case recordedStaleResponse:
+		stale := &StaleResponseError{}
+		if json.Unmarshal(e.ErrorDetail, stale) == nil {
+			stale.Want = id
+			return stale
+		}
+	case recordedTimeout:
+		return context.DeadlineExceeded
+	}
+	return errors.New(e.Error)
+}
+
+type sessionRecorder struct {
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func newSessionRecorder(path string) (*sessionRecorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &sessionRecorder{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (r *sessionRecorder) record(exchange RecordedExchange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.encoder.Encode(exchange)
+}
+
+func (r *sessionRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// recordingTransport passes every exchange through to inner and appends it,
+// with its timing, to the session archive. Cancelled exchanges are skipped.
+type recordingTransport struct {
+	service  string
+	inner    Transport
+	recorder *sessionRecorder
+}
+
+func (t *recordingTransport) Exchange(ctx context.Context, request Request) ([]byte, error) {
+	start := time.Now()
+	response, err := t.inner.Exchange(ctx, request)
+	if errors.Is(err, context.Canceled) {
+		return response, err
+	}
+
+	exchange := RecordedExchange{
+		Service: t.service,
+		Time:    start,
+		Latency: Duration{time.Since(start)},
+		Request: rawJSON(request.Body),
+	}
+	if err != nil {
+		exchange.recordError(err)
+	} else {
+		exchange.Response = rawJSON(response)
+	}
+	t.recorder.record(exchange)
+	return response, err
+}
+
+// rawJSON keeps valid JSON as is and stores anything else as a JSON string
+func rawJSON(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return data
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
+
+func loadSession(path string) ([]RecordedExchange, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var exchanges []RecordedExchange
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var exchange RecordedExchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		exchanges = append(exchanges, exchange)
+	}
+	return exchanges, scanner.Err()
+}
+
+// replayTransport answers from a recorded session instead of a microservice.
+// A request matches a recording of the same service whose body is identical
+// apart from its request_id. Matching recordings are served in order and the
+// last one is repeated once they run out. The response gets the new request's
+// ID. With timing set, the recorded latency is reproduced.
+type replayTransport struct {
+	service string
+	timing  bool
+
+	mu        sync.Mutex
+	exchanges []RecordedExchange
+	served    map[int]bool
+}
+
+func newReplayTransports(exchanges []RecordedExchange, timing bool) map[string]Transport {
+	transports := make(map[string]Transport)
+	for _, name := range serviceNames {
+		t := &replayTransport{service: name, timing: timing, served: make(map[int]bool)}
+		for _, exchange := range exchanges {
+			if exchange.Service == name {
+				t.exchanges = append(t.exchanges, exchange)
+			}
+		}
+		transports[name] = t
+	}
+	return transports
+}
+
+func (t *replayTransport) Exchange(ctx context.Context, request Request) ([]byte, error) {
+	exchange, ok := t.match(request.Body)
+	if !ok {
+		return nil, &ServiceError{Code: "not_recorded", Message: fmt.Sprintf("no recorded %s response for this request", t.service)}
+	}
+
+	if t.timing {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(exchange.Latency.Duration):
+		}
+	}
+
+	if exchange.Error != "" {
+		return nil, exchange.replayError(request.ID)
+	}
+	return withRequestID(exchange.Response, request.ID)
+}
+
+func (t *replayTransport) match(body []byte) (RecordedExchange, bool) {
+	key := requestKey(body)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last := -1
+	for i, exchange := range t.exchanges {
+		if requestKey(exchange.Request) != key {
+			continue
+		}
+		if !t.served[i] {
+			t.served[i] = true
+			return exchange, true
+		}
+		last = i
+	}
+	if last < 0 {
+		return RecordedExchange{}, false
+	}
+	return t.exchanges[last], true
+}
+
+// requestKey is body re-encoded without its request_id. encoding/json sorts
+// map keys, so equal requests produce equal keys.
+func requestKey(body []byte) string {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return string(body)
+	}
+	delete(fields, "request_id")
+	key, _ := json.Marshal(fields)
+	return string(key)
+}
+
+func withRequestID(response []byte, id string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(response, &fields); err != nil {
+		return response, nil
+	}
+	quoted, err := json.Marshal(id)
+	if err != nil {
+		return nil, err
+	}
+	fields["request_id"] = quoted
+	return json.Marshal(fields)
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// REFERENCE MICROSERVICES
+// Built-in implementations of the microservice-a to -d contracts, served
+// through the in-process transport. Their data comes from data/*.json,
+// compiled into the binary and overridable with -data-dir.
+
+//go:embed data/*.json
+var referenceData embed.FS
+
+var referenceDataDir string
+
+func init() {
+	registerHandler(serviceBudget, answerPing(referenceBudget))
+	registerHandler(serviceSummary, answerPing(referenceSummary))
+	registerHandler(serviceStock, answerPing(referenceStock))
+	registerHandler(serviceCrypto, answerPing(referenceCrypto))
+}
+
+// useReferenceServices points every service at its built-in implementation.
+func useReferenceServices(cfg *Config) {
+	for _, name := range serviceNames {
+		svc := cfg.Services[name]
+		svc.Transport = "inprocess"
+		svc.Handler = name
+		svc.Ping = true
+		cfg.Services[name] = svc
+	}
+}
+
+// answerPing replies to heartbeat pings before handing other requests to next
+func answerPing(next Handler) Handler {
+	return func(request []byte) ([]byte, error) {
+		fields, id, err := decodeReferenceRequest(request)
+		if err == nil {
+			if _, ok := fields["ping"]; ok {
+				return json.Marshal(map[string]interface{}{"request_id": id, "pong": 1})
+			}
+		}
+		return next(request)
+	}
+}
+
+func loadReferenceData(name string, v interface{}) error {
+	var data []byte
+	var err error
+	if referenceDataDir != "" {
+		data, err = os.ReadFile(filepath.Join(referenceDataDir, name))
+	}
+	if referenceDataDir == "" || errors.Is(err, os.ErrNotExist) {
+		data, err = referenceData.ReadFile("data/" + name)
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func decodeReferenceRequest(request []byte) (map[string]json.RawMessage, string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(request, &fields); err != nil {
+		return nil, "", fmt.Errorf("invalid request: %w", err)
+	}
+	var id string
+	if raw, ok := fields["request_id"]; ok {
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return nil, "", fmt.Errorf("invalid request_id: %w", err)
+		}
+		delete(fields, "request_id")
+	}
+	delete(fields, "schema_version")
+	return fields, id, nil
+}
+
+func referenceError(id, code, message string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"request_id":     id,
+		"schema_version": schemaVersion,
+		"error":          ServiceError{Code: code, Message: message},
+	})
+}
+
+// Microservice A: splits the total across the categories by percentage
+func referenceBudget(request []byte) ([]byte, error) {
+	fields, id, err := decodeReferenceRequest(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int
+	if err := json.Unmarshal(fields["total"], &total); err != nil {
+		return nil, fmt.Errorf("invalid total: %w", err)
+	}
+	delete(fields, "total")
+
+	response := map[string]interface{}{"request_id": id, "schema_version": schemaVersion, "total": total}
+	for category, raw := range fields {
+		var percentage int
+		if err := json.Unmarshal(raw, &percentage); err != nil {
+			return nil, fmt.Errorf("invalid percentage for %q: %w", category, err)
+		}
+		response[category] = total * percentage / 100
+	}
+	return json.Marshal(response)
+}
+
+// Microservice B: summary of the major indices
+func referenceSummary(request []byte) ([]byte, error) {
+	fields, id, err := decodeReferenceRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := fields["history"]; ok {
+		return referenceIndexHistory(fields, id)
+	}
+	tickers := defaultSummaryIndices
+	if raw, ok := fields["indices"]; ok {
+		if err := json.Unmarshal(raw, &tickers); err != nil {
+			return nil, fmt.Errorf("invalid indices: %w", err)
+		}
+	}
+
+	var known []IndexData
+	if err := loadReferenceData("indices.json", &known); err != nil {
+		return nil, err
+	}
+
+	// Answer in the order asked for, leaving out tickers we have no data for
+	indices := []IndexData{}
+	for _, ticker := range tickers {
+		for _, index := range known {
+			if strings.EqualFold(index.Ticker, ticker) {
+				indices = append(indices, index)
+				break
+			}
+		}
+	}
+	return json.Marshal(summaryResponse{RequestID: id, SchemaVersion: schemaVersion, Indices: indices})
+}
+
+// Microservice B: daily history of one index
+func referenceIndexHistory(fields map[string]json.RawMessage, id string) ([]byte, error) {
+	var ticker, from, to string
+	for name, v := range map[string]*string{"history": &ticker, "from": &from, "to": &to} {
+		if err := json.Unmarshal(fields[name], v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	start, end, err := referenceRange(from, to)
+	if err != nil {
+		return referenceError(id, "invalid_range", err.Error())
+	}
+
+	var known []IndexData
+	if err := loadReferenceData("indices.json", &known); err != nil {
+		return nil, err
+	}
+	for _, index := range known {
+		if strings.EqualFold(index.Ticker, ticker) {
+			return json.Marshal(indexHistoryResponse{
+				RequestID:     id,
+				SchemaVersion: schemaVersion,
+				Ticker:        index.Ticker,
+				Series:        referenceHistory(index, start, end),
+			})
+		}
+	}
+	return referenceError(id, "unknown_index", fmt.Sprintf("no data for index %q", ticker))
+}
+
+// referenceHistory makes up daily prices between from and to for the
+// reference data, which only has the latest day. It walks backwards from
+// latest one trading day at a time with a random walk seeded by the ticker, so
+// every request sees the same history. The result is oldest first.
+func referenceHistory(latest IndexData, from, to time.Time) []IndexData {
+	seed := fnv.New64a()
+	seed.Write([]byte(latest.Ticker))
+	rng := rand.New(rand.NewPCG(seed.Sum64(), 0))
+
+	day, err := time.ParseInLocation(dateLayout, latest.Date, usMarket.Location)
+	if err != nil {
+		return []IndexData{}
+	}
+	series := []IndexData{}
+	bar := latest
+	for !day.Before(from) {
+		if !day.After(to) {
+			bar.Date = day.Format(dateLayout)
+			series = append(series, bar)
+		}
+
+		day = day.AddDate(0, 0, -1)
+		for !usMarket.isTradingDay(day) {
+			day = day.AddDate(0, 0, -1)
+		}
+		previousClose := bar.Open * (1 + rng.NormFloat64()*0.003)
+		open := previousClose * (1 + rng.NormFloat64()*0.004)
+		close := previousClose
+		spread := math.Abs(rng.NormFloat64()) * 0.006
+		bar = IndexData{
+			Open:   round2(open),
+			High:   round2(math.Max(open, close) * (1 + spread)),
+			Low:    round2(math.Min(open, close) * (1 - spread)),
+			Close:  round2(close),
+			Volume: int64(float64(latest.Volume) * (0.7 + 0.6*rng.Float64())),
+			Name:   latest.Name,
+			Ticker: latest.Ticker,
+		}
+	}
+	slices.Reverse(series)
+	return series
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// Microservice C: latest quote for a ticker
+func referenceStock(request []byte) ([]byte, error) {
+	fields, id, err := decodeReferenceRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := fields["history"]; ok {
+		return referenceStockHistory(fields, id)
+	}
+	var ticker string
+	if err := json.Unmarshal(fields["ticker"], &ticker); err != nil {
+		return nil, fmt.Errorf("invalid ticker: %w", err)
+	}
+
+	var stocks []StockData
+	if err := loadReferenceData("stocks.json", &stocks); err != nil {
+		return nil, err
+	}
+	for _, stock := range stocks {
+		if strings.EqualFold(stock.Ticker, ticker) {
+			stock.RequestID = id
+			stock.SchemaVersion = schemaVersion
+			return json.Marshal(stock)
+		}
+	}
+	return referenceError(id, "unknown_ticker", fmt.Sprintf("no quote for ticker %q", ticker))
+}
+
+// Microservice C: daily, weekly or monthly history of one stock
+func referenceStockHistory(fields map[string]json.RawMessage, id string) ([]byte, error) {
+	var ticker, from, to string
+	for name, v := range map[string]*string{"history": &ticker, "from": &from, "to": &to} {
+		if err := json.Unmarshal(fields[name], v); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	interval := "1d"
+	if raw, ok := fields["interval"]; ok {
+		if err := json.Unmarshal(raw, &interval); err != nil {
+			return nil, fmt.Errorf("invalid interval: %w", err)
+		}
+	}
+	if !slices.Contains(stockIntervals, interval) {
+		return referenceError(id, "invalid_interval", fmt.Sprintf("interval must be 1d, 1wk or 1mo, not %q", interval))
+	}
+	start, end, err := referenceRange(from, to)
+	if err != nil {
+		return referenceError(id, "invalid_range", err.Error())
+	}
+
+	var stocks []StockData
+	if err := loadReferenceData("stocks.json", &stocks); err != nil {
+		return nil, err
+	}
+	for _, stock := range stocks {
+		if !strings.EqualFold(stock.Ticker, ticker) {
+			continue
+		}
+		latest := IndexData{Date: stock.Date, Open: stock.Open, High: stock.High, Low: stock.Low, Close: stock.Close, Volume: stock.Volume, Ticker: stock.Ticker}
+		days := referenceHistory(latest, start, end)
+		series := make([]StockBar, len(days))
+		for i, day := range days {
+			series[i] = StockBar{Date: day.Date, Open: day.Open, High: day.High, Low: day.Low, Close: day.Close, Volume: day.Volume}
+		}
+		series = aggregateBars(series, interval)
+		return json.Marshal(stockHistoryResponse{RequestID: id, SchemaVersion: schemaVersion, Ticker: stock.Ticker, Series: series})
+	}
+	return referenceError(id, "unknown_ticker", fmt.Sprintf("no history for ticker %q", ticker))
+}
+
+// aggregateBars merges daily bars into weekly or monthly ones
+func aggregateBars(days []StockBar, interval string) []StockBar {
+	period := func(date string) string {
+		day, _ := time.Parse(dateLayout, date)
+		switch interval {
+		case "1wk":
+			year, week := day.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		case "1mo":
+			return day.Format("2006-01")
+		}
+		return date
+	}
+
+	bars := []StockBar{}
+	current := ""
+	for _, day := range days {
+		if p := period(day.Date); p != current || len(bars) == 0 {
+			current = p
+			bars = append(bars, day)
+			continue
+		}
+		bar := &bars[len(bars)-1]
+		bar.High = math.Max(bar.High, day.High)
+		bar.Low = math.Min(bar.Low, day.Low)
+		bar.Close = day.Close
+		bar.Volume += day.Volume
+	}
+	return bars
+}
+
+// referenceRange parses the dates of a history request
+func referenceRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(dateLayout, from, usMarket.Location)
+	if err != nil {
+		return start, start, fmt.Errorf("bad from date %q", from)
+	}
+	end, err := time.ParseInLocation(dateLayout, to, usMarket.Location)
+	if err != nil || end.Before(start) {
+		return start, end, fmt.Errorf("bad to date %q", to)
+	}
+	return start, end, nil
+}
+
+// Microservice D: current price of a coin
+func referenceCrypto(request []byte) ([]byte, error) {
+	fields, id, err := decodeReferenceRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	var coin string
+	if err := json.Unmarshal(fields["coin"], &coin); err != nil {
+		return nil, fmt.Errorf("invalid coin: %w", err)
+	}
+
+	var prices map[string]float64
+	if err := loadReferenceData("crypto.json", &prices); err != nil {
+		return nil, err
+	}
+	coin = strings.ToLower(strings.TrimSpace(coin))
+	price, ok := prices[coin]
+	if !ok {
+		return referenceError(id, "unknown_coin", fmt.Sprintf("no price for coin %q", coin))
+	}
+	return json.Marshal(map[string]interface{}{"request_id": id, "schema_version": schemaVersion, coin: price})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// Request is one outgoing microservice call. Every request body carries ID in
+// its "request_id" field and the response must echo it back.
+type Request struct {
+	ID   string
+	Body []byte
+	// Contract names the schemas the exchange follows; empty means the
+	// service's own.
+	Contract string
+	OnStale  func(*StaleResponseError)
+}
+
+func (r Request) reportStale(err *StaleResponseError) {
+	if r.OnStale != nil {
+		r.OnStale(err)
+	}
+}
+
+type StaleResponseError struct {
+	Want string
+	Got  string
+}
+
+func (e *StaleResponseError) Error() string {
+	if e.Got == "" {
+		return fmt.Sprintf("discarded response without request_id (expected %s)", e.Want)
+	}
+	return fmt.Sprintf("discarded stale response %s (expected %s)", e.Got, e.Want)
+}
+
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// checkResponseID returns a *StaleResponseError when data answers a different
+// request. Data that is not a JSON object yet is left for the decoder to reject.
+func checkResponseID(data []byte, id string) error {
+	var envelope struct {
+		RequestID string `json:"request_id"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		if _, ok := err.(*json.UnmarshalTypeError); !ok {
+			return nil
+		}
+	}
+	if envelope.RequestID != id {
+		return &StaleResponseError{Want: id, Got: envelope.RequestID}
+	}
+	return nil
+}
+
+// ServiceError is the envelope a microservice answers with when it cannot
+// serve a request:
+//
+//	{"request_id": "...", "error": {"code": "unknown_ticker", "message": "..."}}
+type ServiceError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ServiceError) Error() string {
+	return fmt.Sprintf("[%s] %s", e.Code, e.Message)
+}
+
+// checkErrorEnvelope returns the *ServiceError carried by data, if any. A
+// bare string in "error" is accepted with the code "error"; a null "error" is
+// no error.
+func checkErrorEnvelope(data []byte) error {
+	var envelope struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil || len(envelope.Error) == 0 || string(envelope.Error) == "null" {
+		return nil
+	}
+
+	var serviceErr ServiceError
+	if err := json.Unmarshal(envelope.Error, &serviceErr); err == nil {
+		if serviceErr.Code == "" {
+			serviceErr.Code = "error"
+		}
+		return &serviceErr
+	}
+	var message string
+	if err := json.Unmarshal(envelope.Error, &message); err == nil {
+		return &ServiceError{Code: "error", Message: message}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckResponseID(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want *StaleResponseError
+	}{
+		{name: "matching", data: `{"request_id": "abc", "Close": 1}`},
+		{name: "stale", data: `{"request_id": "old"}`, want: &StaleResponseError{Want: "abc", Got: "old"}},
+		{name: "without request_id", data: `{"Close": 1}`, want: &StaleResponseError{Want: "abc"}},
+		{name: "request_id of the wrong type", data: `{"request_id": 7}`, want: &StaleResponseError{Want: "abc"}},
+		{name: "not an object", data: `[1, 2]`, want: &StaleResponseError{Want: "abc"}},
+		{name: "truncated", data: `{"request_id": "abc"`},
+		{name: "not JSON", data: `Traceback (most recent call last)`},
+	}
+	for _, test := range tests {
+		err := checkResponseID([]byte(test.data), "abc")
+		if test.want == nil {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		var stale *StaleResponseError
+		if !errors.As(err, &stale) || *stale != *test.want {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestCheckErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want *ServiceError
+	}{
+		{name: "no error", data: `{"request_id": "abc", "Close": 1}`},
+		{name: "null error", data: `{"request_id": "abc", "error": null}`},
+		{name: "code and message", data: `{"request_id": "abc", "error": {"code": "unknown_ticker", "message": "no such ticker"}}`,
+			want: &ServiceError{Code: "unknown_ticker", Message: "no such ticker"}},
+		{name: "message only", data: `{"error": {"message": "try later"}}`, want: &ServiceError{Code: "error", Message: "try later"}},
+		{name: "bare string", data: `{"error": "service down"}`, want: &ServiceError{Code: "error", Message: "service down"}},
+		{name: "unreadable error", data: `{"error": 42}`},
+		{name: "not JSON", data: `error`},
+	}
+	for _, test := range tests {
+		err := checkErrorEnvelope([]byte(test.data))
+		if test.want == nil {
+			if err != nil {
+				t.Errorf("%s: %v", test.name, err)
+			}
+			continue
+		}
+		var serviceErr *ServiceError
+		if !errors.As(err, &serviceErr) || *serviceErr != *test.want {
+			t.Errorf("%s: got %v, want %v", test.name, err, test.want)
+		}
+	}
+}
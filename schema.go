@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// SCHEMAS
+// Every request and response carries "schema_version". Responses are checked
+// strictly against the schema for their service: missing fields, fields of
+// the wrong type and unexpected fields are all reported.
+
+const schemaVersion = 1
+
+type FieldSchema struct {
+	Name        string
+	Type        string // "string", "number", "integer", "object" or "array"
+	Required    bool
+	Description string
+	Items       *Schema        // element schema of an array of objects
+	Pattern     *regexp.Regexp // strings must match it
+}
+
+type Schema struct {
+	Name   string
+	Fields []FieldSchema
+	// Additional is the type of any further fields (budget categories, crypto
+	// coins); empty means no further fields are allowed.
+	Additional string
+}
+
+type Contract struct {
+	Request  *Schema
+	Response *Schema
+}
+
+var envelopeFields = []FieldSchema{
+	{Name: "request_id", Type: "string", Required: true, Description: "echoed from the request"},
+	{Name: "schema_version", Type: "integer", Required: true, Description: fmt.Sprintf("always %d", schemaVersion)},
+}
+
+func envelope(name string, additional string, fields ...FieldSchema) *Schema {
+	return &Schema{Name: name, Fields: append(append([]FieldSchema{}, envelopeFields...), fields...), Additional: additional}
+}
+
+var indexSchema = &Schema{
+	Name: "index",
+	Fields: []FieldSchema{
+		{Name: "Date", Type: "string", Required: true},
+		{Name: "Open", Type: "number", Required: true},
+		{Name: "High", Type: "number", Required: true},
+		{Name: "Low", Type: "number", Required: true},
+		{Name: "Close", Type: "number", Required: true},
+		{Name: "Volume", Type: "integer", Required: true},
+		{Name: "Name", Type: "string", Required: true},
+		{Name: "Ticker", Type: "string", Required: true},
+		{Name: "PrevClose", Type: "number", Description: "previous trading day's close"},
+	},
+}
+
+var stockBarSchema = &Schema{
+	Name: "bar",
+	Fields: []FieldSchema{
+		{Name: "Date", Type: "string", Required: true},
+		{Name: "Open", Type: "number", Required: true},
+		{Name: "High", Type: "number", Required: true},
+		{Name: "Low", Type: "number", Required: true},
+		{Name: "Close", Type: "number", Required: true},
+		{Name: "Volume", Type: "integer", Required: true},
+	},
+}
+
+// contracts is keyed by service name; services that take more than one kind
+// of request have further contracts named "<service>.<kind>".
+var contracts = map[string]Contract{
+	serviceBudget: {
+		Request: envelope("budget.request", "integer",
+			FieldSchema{Name: "total", Type: "integer", Required: true, Description: "total budget; other fields are category percentages"}),
+		Response: envelope("budget.response", "integer",
+			FieldSchema{Name: "total", Type: "integer", Required: true, Description: "total budget; other fields are category amounts"}),
+	},
+	serviceSummary: {
+		Request: envelope("summary.request", "",
+			FieldSchema{Name: "summary", Type: "integer", Required: true},
+			FieldSchema{Name: "indices", Type: "array", Description: "tickers to summarise; the Dow, S&P 500 and NASDAQ when absent"}),
+		Response: envelope("summary.response", "",
+			FieldSchema{Name: "indices", Type: "array", Required: true, Items: indexSchema}),
+	},
+	serviceSummary + ".history": {
+		Request: envelope("summary.history.request", "",
+			FieldSchema{Name: "history", Type: "string", Required: true, Description: "ticker of the index"},
+			FieldSchema{Name: "from", Type: "string", Required: true, Description: "first date, YYYY-MM-DD"},
+			FieldSchema{Name: "to", Type: "string", Required: true, Description: "last date, YYYY-MM-DD"}),
+		Response: envelope("summary.history.response", "",
+			FieldSchema{Name: "ticker", Type: "string", Required: true},
+			FieldSchema{Name: "series", Type: "array", Required: true, Items: indexSchema, Description: "one entry per trading day, oldest first"}),
+	},
+	serviceStock: {
+		Request: envelope("stock.request", "",
+			FieldSchema{Name: "ticker", Type: "string", Required: true, Pattern: tickerPattern}),
+		Response: envelope("stock.response", "",
+			FieldSchema{Name: "Ticker", Type: "string", Required: true},
+			FieldSchema{Name: "Date", Type: "string", Required: true},
+			FieldSchema{Name: "Open", Type: "number", Required: true},
+			FieldSchema{Name: "High", Type: "number", Required: true},
+			FieldSchema{Name: "Low", Type: "number", Required: true},
+			FieldSchema{Name: "Close", Type: "number", Required: true},
+			FieldSchema{Name: "Volume", Type: "integer"}),
+	},
+	serviceStock + ".history": {
+		Request: envelope("stock.history.request", "",
+			FieldSchema{Name: "history", Type: "string", Required: true, Description: "ticker of the stock", Pattern: tickerPattern},
+			FieldSchema{Name: "from", Type: "string", Required: true, Description: "first date, YYYY-MM-DD"},
+			FieldSchema{Name: "to", Type: "string", Required: true, Description: "last date, YYYY-MM-DD"},
+			FieldSchema{Name: "interval", Type: "string", Description: "length of each bar: 1d (the default), 1wk or 1mo"}),
+		Response: envelope("stock.history.response", "",
+			FieldSchema{Name: "ticker", Type: "string", Required: true},
+			FieldSchema{Name: "series", Type: "array", Required: true, Items: stockBarSchema, Description: "one entry per interval, oldest first, dated by its first trading day"}),
+	},
+	serviceCrypto: {
+		Request: envelope("crypto.request", "",
+			FieldSchema{Name: "coin", Type: "string", Required: true}),
+		Response: envelope("crypto.response", "number"),
+	},
+}
+
+var errorSchema = envelope("error.response", "",
+	FieldSchema{Name: "error", Type: "object", Required: true, Items: &Schema{
+		Name: "error",
+		Fields: []FieldSchema{
+			{Name: "code", Type: "string", Required: true},
+			{Name: "message", Type: "string", Required: true},
+		},
+	}})
+
+// VALIDATION
+
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+type ValidationError struct {
+	Schema string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		problems[i] = f.Path + ": " + f.Message
+	}
+	return fmt.Sprintf("%s v%d: %s", e.Schema, schemaVersion, strings.Join(problems, "; "))
+}
+
+func validateRequest(contract string, data []byte) error {
+	return validate(contracts[contract].Request, data)
+}
+
+func validateResponse(contract string, data []byte) error {
+	return validate(contracts[contract].Response, data)
+}
+
+func validate(schema *Schema, data []byte) error {
+	if schema == nil {
+		return nil
+	}
+
+	var doc interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return &ValidationError{Schema: schema.Name, Fields: []FieldError{{Path: "$", Message: "invalid JSON: " + err.Error()}}}
+	}
+
+	var problems []FieldError
+	object, ok := doc.(map[string]interface{})
+	if !ok {
+		problems = append(problems, FieldError{Path: "$", Message: "expected an object, got " + jsonType(doc)})
+	} else {
+		problems = validateObject(schema, object, "", problems)
+		if v, ok := object["schema_version"].(json.Number); ok && v.String() != fmt.Sprint(schemaVersion) {
+			problems = append(problems, FieldError{Path: "schema_version", Message: fmt.Sprintf("unsupported version %s, expected %d", v, schemaVersion)})
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Schema: schema.Name, Fields: problems}
+	}
+	return nil
+}
+
+func validateObject(schema *Schema, object map[string]interface{}, prefix string, problems []FieldError) []FieldError {
+	known := make(map[string]bool)
+	for _, field := range schema.Fields {
+		known[field.Name] = true
+		path := prefix + field.Name
+		value, ok := object[field.Name]
+		if !ok {
+			if field.Required {
+				problems = append(problems, FieldError{Path: path, Message: "missing " + field.Type})
+			}
+			continue
+		}
+		problems = validateValue(field.Type, field.Items, value, path, problems)
+		if s, ok := value.(string); ok && field.Pattern != nil && !field.Pattern.MatchString(s) {
+			problems = append(problems, FieldError{Path: path, Message: fmt.Sprintf("%q does not match %s", s, field.Pattern)})
+		}
+	}
+
+	// Report extra fields in a stable order
+	var extra []string
+	for name := range object {
+		if !known[name] {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		if schema.Additional == "" {
+			problems = append(problems, FieldError{Path: prefix + name, Message: "unexpected field"})
+			continue
+		}
+		problems = validateValue(schema.Additional, nil, object[name], prefix+name, problems)
+	}
+	return problems
+}
+
+func validateValue(want string, items *Schema, value interface{}, path string, problems []FieldError) []FieldError {
+	got := jsonType(value)
+	if got != want && !(want == "number" && got == "integer") {
+		return append(problems, FieldError{Path: path, Message: fmt.Sprintf("expected %s, got %s", want, got)})
+	}
+	if items == nil {
+		return problems
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		for i, element := range v {
+			elementPath := fmt.Sprintf("%s[%d]", path, i)
+			object, ok := element.(map[string]interface{})
+			if !ok {
+				problems = append(problems, FieldError{Path: elementPath, Message: "expected object, got " + jsonType(element)})
+				continue
+			}
+			problems = validateObject(items, object, elementPath+".", problems)
+		}
+	case map[string]interface{}:
+		problems = validateObject(items, v, path+".", problems)
+	}
+	return problems
+}
+
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "number"
+		}
+		return "integer"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// JSON SCHEMA EXPORT
+
+// dumpSchemas renders every contract as a JSON Schema document, keyed by
+// schema name, for teammates validating their microservices.
+func dumpSchemas() ([]byte, error) {
+	docs := make(map[string]interface{})
+	for _, contract := range contracts {
+		for _, schema := range []*Schema{contract.Request, contract.Response} {
+			docs[schema.Name] = jsonSchema(schema, true)
+		}
+	}
+	docs[errorSchema.Name] = jsonSchema(errorSchema, true)
+	return json.MarshalIndent(docs, "", "  ")
+}
+
+func jsonSchema(schema *Schema, root bool) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+	for _, field := range schema.Fields {
+		property := map[string]interface{}{"type": field.Type}
+		if field.Pattern != nil {
+			property["pattern"] = field.Pattern.String()
+		}
+		if field.Description != "" {
+			property["description"] = field.Description
+		}
+		if field.Name == "schema_version" {
+			property["const"] = schemaVersion
+		}
+		if field.Items != nil {
+			if field.Type == "array" {
+				property["items"] = jsonSchema(field.Items, false)
+			} else {
+				property = jsonSchema(field.Items, false)
+			}
+		}
+		properties[field.Name] = property
+		if field.Required {
+			required = append(required, field.Name)
+		}
+	}
+
+	doc := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+	if schema.Additional == "" {
+		doc["additionalProperties"] = false
+	} else {
+		doc["additionalProperties"] = map[string]interface{}{"type": schema.Additional}
+	}
+	if root {
+		doc["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+		doc["$id"] = fmt.Sprintf("%s.v%d", schema.Name, schemaVersion)
+		doc["title"] = schema.Name
+	}
+	return doc
+}
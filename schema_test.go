@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	const stock = `"request_id": "1", "schema_version": 1, "Ticker": "AAPL", "Date": "2025-05-30", "Open": 199.37, "High": 201.96, "Low": 196.78`
+	tests := []struct {
+		name   string
+		schema *Schema
+		data   string
+		want   []string
+	}{
+		{name: "valid", schema: contracts[serviceStock].Response, data: `{` + stock + `, "Close": 200.85, "Volume": 70819900}`},
+		{name: "optional field absent", schema: contracts[serviceStock].Response, data: `{` + stock + `, "Close": 200.85}`},
+		{name: "integer for a number", schema: contracts[serviceStock].Response, data: `{` + stock + `, "Close": 200}`},
+		{name: "number for an integer", schema: contracts[serviceStock].Response, data: `{` + stock + `, "Close": 200.85, "Volume": 1.5}`,
+			want: []string{"Volume: expected integer, got number"}},
+		{name: "missing field", schema: contracts[serviceStock].Response, data: `{` + stock + `}`,
+			want: []string{"Close: missing number"}},
+		{name: "wrong type", schema: contracts[serviceStock].Response, data: `{` + stock + `, "Close": "200.85"}`,
+			want: []string{"Close: expected number, got string"}},
+		{name: "extra fields in name order", schema: contracts[serviceStock].Response, data: `{` + stock + `, "Close": 200.85, "b": 1, "a": null}`,
+			want: []string{"a: unexpected field", "b: unexpected field"}},
+		{name: "additional fields", schema: contracts[serviceBudget].Response, data: `{"request_id": "1", "schema_version": 1, "total": 1000, "rent": 500, "food": 250}`},
+		{name: "additional field of the wrong type", schema: contracts[serviceBudget].Response, data: `{"request_id": "1", "schema_version": 1, "total": 1000, "rent": 500.5, "food": "250"}`,
+			want: []string{"food: expected integer, got string", "rent: expected integer, got number"}},
+		{name: "additional numbers accept integers", schema: contracts[serviceCrypto].Response, data: `{"request_id": "1", "schema_version": 1, "bitcoin": 104000, "ethereum": 2500.5}`},
+		{name: "wrong schema_version", schema: contracts[serviceCrypto].Response, data: `{"request_id": "1", "schema_version": 2}`,
+			want: []string{"schema_version: unsupported version 2, expected 1"}},
+		{name: "schema_version as a string", schema: contracts[serviceCrypto].Response, data: `{"request_id": "1", "schema_version": "1"}`,
+			want: []string{"schema_version: expected integer, got string"}},
+		{name: "missing envelope", schema: contracts[serviceCrypto].Response, data: `{}`,
+			want: []string{"request_id: missing string", "schema_version: missing integer"}},
+		{name: "pattern", schema: contracts[serviceStock].Request, data: `{"request_id": "1", "schema_version": 1, "ticker": "aa!pl"}`,
+			want: []string{`ticker: "aa!pl" does not match ` + tickerPattern.String()}},
+		{name: "array elements", schema: contracts[serviceSummary].Response, data: `{"request_id": "1", "schema_version": 1, "indices": [{"Date": "2025-05-30", "Open": 1, "High": 1, "Low": 1, "Close": 1, "Volume": 1, "Name": "Dow", "Ticker": "^DJI"}, {"Date": "2025-05-30"}, 3]}`,
+			want: []string{
+				"indices[1].Open: missing number", "indices[1].High: missing number", "indices[1].Low: missing number", "indices[1].Close: missing number",
+				"indices[1].Volume: missing integer", "indices[1].Name: missing string", "indices[1].Ticker: missing string",
+				"indices[2]: expected object, got integer",
+			}},
+		{name: "error envelope", schema: errorSchema, data: `{"request_id": "1", "schema_version": 1, "error": {"code": "unknown_ticker", "message": "no such ticker"}}`},
+		{name: "error envelope without a message", schema: errorSchema, data: `{"request_id": "1", "schema_version": 1, "error": {"code": "unknown_ticker", "detail": 1}}`,
+			want: []string{"error.message: missing string", "error.detail: unexpected field"}},
+		{name: "not an object", schema: errorSchema, data: `[]`,
+			want: []string{"$: expected an object, got array"}},
+	}
+	for _, test := range tests {
+		err := validate(test.schema, []byte(test.data))
+		var got []string
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			if validationErr.Schema != test.schema.Name {
+				t.Errorf("%s: schema %q, want %q", test.name, validationErr.Schema, test.schema.Name)
+			}
+			for _, field := range validationErr.Fields {
+				got = append(got, field.Path+": "+field.Message)
+			}
+		} else if err != nil {
+			t.Errorf("%s: %v is not a *ValidationError", test.name, err)
+			continue
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestValidateInvalidJSON(t *testing.T) {
+	var validationErr *ValidationError
+	err := validate(contracts[serviceStock].Response, []byte(`{"Ticker": `))
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 || validationErr.Fields[0].Path != "$" {
+		t.Errorf("validate = %v, want one problem at $", err)
+	}
+	if err := validate(nil, []byte(`not JSON`)); err != nil {
+		t.Errorf("validate without a schema = %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+// Service is a configured microservice: its transport plus the timeout and
+// retry policy applied to every call.
+type Service struct {
+	Name      string
+	Transport Transport
+	Timeout   time.Duration
+	Retries   int
+	Backoff   time.Duration
+	Status    *statusBoard
+	// Cache, if set, answers requests whose contract it caches
+	Cache *quoteCache
+	// turn, if set, lets one attempt at a time use the transport, for
+	// transports that cannot carry requests alongside each other
+	turn chan struct{}
+}
+
+type NoResponseError struct {
+	Service  string
+	Timeout  time.Duration
+	Attempts int
+}
+
+func (e *NoResponseError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s service did not respond within %s (%d attempts)", e.Service, e.Timeout, e.Attempts)
+	}
+	return fmt.Sprintf("%s service did not respond within %s", e.Service, e.Timeout)
+}
+
+func newService(name string, cfg ServiceConfig) (*Service, error) {
+	transport, err := newTransport(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+	svc := &Service{
+		Name:      name,
+		Transport: transport,
+		Timeout:   cfg.Timeout.Duration,
+		Retries:   cfg.Retries,
+		Backoff:   cfg.Backoff.Duration,
+	}
+	if !cfg.independentRequests() {
+		svc.turn = make(chan struct{}, 1)
+	}
+	return svc, nil
+}
+
+func buildServices(cfg Config, board *statusBoard) (map[string]*Service, error) {
+	services := make(map[string]*Service)
+	for _, name := range serviceNames {
+		svc, err := newService(name, cfg.Services[name])
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		svc.Status = board
+		services[name] = svc
+	}
+	return services, nil
+}
+
+// call sends request, giving each attempt s.Timeout to answer once it has its
+// turn on the transport, so time spent queued behind other requests does not
+// count against it. Failed attempts are retried up to s.Retries times,
+// re-sending the same request after an exponentially growing backoff. An
+// error envelope in the response is returned as a *ServiceError and a response
+// that does not match the service's schema as a *ValidationError; neither is
+// retried. The outcome is recorded on s.Status.
+func (s *Service) call(ctx context.Context, request Request) ([]byte, error) {
+	start := time.Now()
+	response, err := s.attempt(ctx, request)
+	if s.Status != nil && ctx.Err() == nil {
+		if err != nil {
+			s.Status.recordError(s.Name, err)
+		} else {
+			s.Status.recordSuccess(s.Name, time.Since(start))
+		}
+	}
+	return response, err
+}
+
+func (s *Service) attempt(ctx context.Context, request Request) ([]byte, error) {
+	backoff := s.Backoff
+	attempts := 0
+	for {
+		attempts++
+		response, err := s.exchange(ctx, request)
+		if err == nil {
+			if err := checkErrorEnvelope(response); err != nil {
+				return nil, err
+			}
+			contract := request.Contract
+			if contract == "" {
+				contract = s.Name
+			}
+			if err := validateResponse(contract, response); err != nil {
+				return nil, err
+			}
+			return response, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var serviceErr *ServiceError
+		if errors.As(err, &serviceErr) {
+			return nil, err
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = &NoResponseError{Service: s.Name, Timeout: s.Timeout, Attempts: attempts}
+		}
+		if attempts > s.Retries {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// exchange makes one attempt, waiting for the transport's turn first if it
+// has one
+func (s *Service) exchange(ctx context.Context, request Request) ([]byte, error) {
+	if s.turn != nil {
+		select {
+		case s.turn <- struct{}{}:
+			defer func() { <-s.turn }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	return s.Transport.Exchange(ctx, request)
+}
+
+// fetch builds a request with a fresh ID, runs the call in the background and
+// hands the decoded response (or the first error) back on the UI goroutine.
+// Discarded stale responses are reported through onStale. Nothing is reported
+// once ctx is cancelled.
+func fetch[T any](ctx context.Context, app *tview.Application, svc *Service, build func(id string) ([]byte, error), decode func([]byte) (T, error), onLoaded func(T), onError func(error), onStale func(error)) {
+	fetchAs(ctx, app, svc, svc.Name, build, decode, onLoaded, onError, onStale)
+}
+
+// fetchAs is fetch for a request that follows one of the service's other
+// contracts, such as "summary.history".
+func fetchAs[T any](ctx context.Context, app *tview.Application, svc *Service, contract string, build func(id string) ([]byte, error), decode func([]byte) (T, error), onLoaded func(T), onError func(error), onStale func(error)) {
+	id := newRequestID()
+	body, err := build(id)
+	if err == nil {
+		err = validateRequest(contract, body)
+	}
+	if err != nil {
+		onError(err)
+		return
+	}
+	request := Request{ID: id, Body: body, Contract: contract, OnStale: func(err *StaleResponseError) {
+		app.QueueUpdateDraw(func() {
+			if ctx.Err() == nil {
+				onStale(err)
+			}
+		})
+	}}
+
+	go func() {
+		var result T
+		response, err := svc.call(ctx, request)
+		if err == nil {
+			result, err = decode(response)
+		}
+		app.QueueUpdateDraw(func() {
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				onError(err)
+				return
+			}
+			onLoaded(result)
+		})
+	}()
+}
+
+// pendingRequest tracks the in-flight call of one page so that a new search,
+// or leaving the page, cancels the previous wait.
+type pendingRequest struct {
+	cancel context.CancelFunc
+}
+
+func (p *pendingRequest) start() context.Context {
+	p.stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	return ctx
+}
+
+func (p *pendingRequest) stop() {
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
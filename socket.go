@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// UNIX SOCKET TRANSPORT
+// Newline-delimited JSON-RPC 2.0 over a Unix domain socket. The request and
+// response payloads are the same JSON documents the file transport exchanges:
+//
+//	→ {"jsonrpc": "2.0", "method": "stock", "params": {...request...}, "id": "<request_id>"}
+//	← {"jsonrpc": "2.0", "result": {...response...}, "id": "<request_id>"}
+//	← {"jsonrpc": "2.0", "error": {"code": -32000, "message": "..."}, "id": "<request_id>"}
+//
+// One connection is shared by all requests; responses may arrive in any order
+// and are paired with their request by id.
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      string          `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+	ID      string          `json:"id"`
+}
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// serviceError maps a JSON-RPC error onto the error envelope. A string "code"
+// in the error data is used as is, otherwise the numeric code.
+func (e *rpcError) serviceError() *ServiceError {
+	var data struct {
+		Code string `json:"code"`
+	}
+	if len(e.Data) > 0 && json.Unmarshal(e.Data, &data) == nil && data.Code != "" {
+		return &ServiceError{Code: data.Code, Message: e.Message}
+	}
+	return &ServiceError{Code: strconv.Itoa(e.Code), Message: e.Message}
+}
+
+var errNoSocket = errors.New("unix transport requires a socket")
+
+// socketWriteTimeout bounds a write when the request has no deadline of its
+// own, so a service that stops reading cannot hold up every request
+const socketWriteTimeout = 10 * time.Second
+
+type socketTransport struct {
+	path   string
+	method string
+
+	mu      sync.Mutex
+	conn    net.Conn
+	pending map[string]chan rpcResponse
+}
+
+func newSocketTransport(path, method string) *socketTransport {
+	return &socketTransport{path: path, method: method, pending: make(map[string]chan rpcResponse)}
+}
+
+func (t *socketTransport) Exchange(ctx context.Context, request Request) ([]byte, error) {
+	reply := make(chan rpcResponse, 1)
+	if err := t.send(ctx, request, reply); err != nil {
+		return nil, err
+	}
+	defer t.forget(request.ID, reply)
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case response, ok := <-reply:
+		if !ok {
+			return nil, fmt.Errorf("connection to %s closed", t.path)
+		}
+		if response.Error != nil {
+			return nil, response.Error.serviceError()
+		}
+		if err := checkResponseID(response.Result, request.ID); err != nil {
+			return nil, err
+		}
+		return response.Result, nil
+	}
+}
+
+func (t *socketTransport) send(ctx context.Context, request Request, reply chan rpcResponse) error {
+	line, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: t.method, Params: request.Body, ID: request.ID})
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "unix", t.path)
+		if err != nil {
+			return err
+		}
+		t.conn = conn
+		go t.read(conn)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(socketWriteTimeout)
+	}
+	t.pending[request.ID] = reply
+	t.conn.SetWriteDeadline(deadline)
+	if _, err := t.conn.Write(append(line, '\n')); err != nil {
+		delete(t.pending, request.ID)
+		t.drop(t.conn)
+		return err
+	}
+	return nil
+}
+
+func (t *socketTransport) forget(id string, reply chan rpcResponse) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.pending[id] == reply {
+		delete(t.pending, id)
+	}
+}
+
+// read dispatches responses until the connection fails, then drops it.
+func (t *socketTransport) read(conn net.Conn) {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var response rpcResponse
+		if err := json.Unmarshal(scanner.Bytes(), &response); err != nil {
+			continue
+		}
+		t.mu.Lock()
+		if reply, ok := t.pending[response.ID]; ok {
+			delete(t.pending, response.ID)
+			reply <- response
+		}
+		t.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.drop(conn)
+}
+
+// drop closes conn and, if it is the current connection, fails every pending
+// reply so the next Exchange dials again. t.mu must be held.
+func (t *socketTransport) drop(conn net.Conn) {
+	conn.Close()
+	if t.conn == conn {
+		t.conn = nil
+		for id, reply := range t.pending {
+			close(reply)
+			delete(t.pending, id)
+		}
+	}
+}
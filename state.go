@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SAVED STATE
+// Choices made inside the app are kept as JSON files in the same directory as
+// the config file, so -config and FINANCE_TUI_CONFIG move them along with it.
+
+func statePath(configPath, name string) string {
+	return filepath.Join(filepath.Dir(configPath), name)
+}
+
+// loadState decodes the file at path into v. It reports false, and leaves v
+// alone, when the file does not exist.
+func loadState(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	return true, nil
+}
+
+func saveState(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// ServiceStatus is what we currently know about one microservice's health.
+type ServiceStatus struct {
+	Name          string
+	Config        ServiceConfig
+	LastHeartbeat time.Time
+	LastSuccess   time.Time
+	LastLatency   time.Duration
+	LastError     string
+	LastErrorAt   time.Time
+	Process       string
+}
+
+func (s ServiceStatus) monitored() bool {
+	return s.Config.Heartbeat != "" || s.Config.Ping
+}
+
+// color is green when the service is answering, yellow when its heartbeat
+// is fine but the last request failed, red when it is failing or its
+// heartbeat is overdue and gray when nothing is known yet.
+func (s ServiceStatus) color(now time.Time, interval time.Duration) string {
+	failing := !s.LastErrorAt.IsZero() && s.LastErrorAt.After(s.LastSuccess)
+	if s.monitored() {
+		if s.LastHeartbeat.IsZero() || now.Sub(s.LastHeartbeat) > 3*interval {
+			return "red"
+		}
+		if failing {
+			return "yellow"
+		}
+		return "green"
+	}
+	switch {
+	case failing:
+		return "red"
+	case !s.LastSuccess.IsZero():
+		return "green"
+	default:
+		return "gray"
+	}
+}
+
+// statusBoard collects call results and heartbeats from every service and
+// notifies listeners (the UI) when anything changes. It is safe for use from
+// the service goroutines; listeners must not block.
+type statusBoard struct {
+	mu        sync.Mutex
+	interval  time.Duration
+	statuses  map[string]*ServiceStatus
+	listeners []func()
+}
+
+func newStatusBoard(cfg Config) *statusBoard {
+	b := &statusBoard{
+		interval: cfg.HeartbeatInterval.Duration,
+		statuses: make(map[string]*ServiceStatus),
+	}
+	for _, name := range serviceNames {
+		b.statuses[name] = &ServiceStatus{Name: name, Config: cfg.Services[name]}
+	}
+	return b
+}
+
+func (b *statusBoard) subscribe(listener func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listeners = append(b.listeners, listener)
+}
+
+func (b *statusBoard) update(name string, apply func(*ServiceStatus)) {
+	b.mu.Lock()
+	apply(b.statuses[name])
+	listeners := append([]func(){}, b.listeners...)
+	b.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+func (b *statusBoard) recordSuccess(name string, latency time.Duration) {
+	b.update(name, func(s *ServiceStatus) {
+		s.LastSuccess = time.Now()
+		s.LastLatency = latency
+	})
+}
+
+func (b *statusBoard) recordError(name string, err error) {
+	b.update(name, func(s *ServiceStatus) {
+		s.LastError = err.Error()
+		s.LastErrorAt = time.Now()
+	})
+}
+
+func (b *statusBoard) recordHeartbeat(name string, at time.Time) {
+	b.update(name, func(s *ServiceStatus) {
+		s.LastHeartbeat = at
+	})
+}
+
+func (b *statusBoard) snapshot() []ServiceStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	statuses := make([]ServiceStatus, 0, len(serviceNames))
+	for _, name := range serviceNames {
+		statuses = append(statuses, *b.statuses[name])
+	}
+	return statuses
+}
+
+// HEARTBEATS
+
+// monitorHeartbeats checks every service that has a heartbeat file or ping
+// configured once per interval until ctx is done.
+func monitorHeartbeats(ctx context.Context, services map[string]*Service, board *statusBoard) {
+	for _, name := range serviceNames {
+		svc := services[name]
+		cfg := board.statuses[name].Config
+		if cfg.Heartbeat == "" && !(cfg.Ping && cfg.independentRequests()) {
+			continue
+		}
+		go func() {
+			ticker := time.NewTicker(board.interval)
+			defer ticker.Stop()
+			for {
+				checkHeartbeat(ctx, svc, cfg, board)
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+func checkHeartbeat(ctx context.Context, svc *Service, cfg ServiceConfig, board *statusBoard) {
+	if cfg.Heartbeat != "" {
+		if info, err := os.Stat(resolvePath(cfg.Root, cfg.Heartbeat)); err == nil {
+			board.recordHeartbeat(svc.Name, info.ModTime())
+		}
+		return
+	}
+	if err := svc.ping(ctx); err != nil {
+		if ctx.Err() == nil {
+			board.recordError(svc.Name, fmt.Errorf("ping: %w", err))
+		}
+		return
+	}
+	board.recordHeartbeat(svc.Name, time.Now())
+}
+
+// ping sends {"ping": 1} and expects any response echoing the request ID. It
+// is only used on transports with independent requests.
+func (s *Service) ping(ctx context.Context) error {
+	id := newRequestID()
+	body, err := json.Marshal(map[string]interface{}{"request_id": id, "ping": 1})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.Timeout)
+	defer cancel()
+	_, err = s.Transport.Exchange(ctx, Request{ID: id, Body: body})
+	return err
+}
+
+// STATUS VIEWS
+
+// newPageHeader places title on the left of a page's first rows and, on the
+// right, a compact service status indicator above the market session.
+func newPageHeader(app *tview.Application, title *tview.TextView, board *statusBoard, clock *marketClock) *tview.Flex {
+	indicator := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignRight)
+	indicator.SetText(renderStatusIndicator(board.snapshot(), board.interval))
+	board.subscribe(func() {
+		go app.QueueUpdateDraw(func() {
+			indicator.SetText(renderStatusIndicator(board.snapshot(), board.interval))
+		})
+	})
+
+	market := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignRight)
+	market.SetText(renderMarketIndicator(clock.calendar, time.Now()))
+	clock.subscribe(func(now time.Time) {
+		go app.QueueUpdateDraw(func() {
+			market.SetText(renderMarketIndicator(clock.calendar, now))
+		})
+	})
+
+	return tview.NewFlex().
+		AddItem(title, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(indicator, 1, 0, false).
+			AddItem(market, 1, 0, false), 60, 0, false)
+}
+
+func renderStatusIndicator(statuses []ServiceStatus, interval time.Duration) string {
+	now := time.Now()
+	parts := make([]string, 0, len(statuses))
+	for _, s := range statuses {
+		parts = append(parts, fmt.Sprintf("[%s]●[-] %s", s.color(now, interval), s.Name))
+	}
+	return strings.Join(parts, "  ")
+}
+
+func renderStatusTable(table *tview.Table, statuses []ServiceStatus, interval time.Duration) {
+	table.Clear()
+
+	headers := []string{"Service", "Transport", "Endpoint", "Process", "Last heartbeat", "Latency", "Last error"}
+	for col, h := range headers {
+		table.SetCell(0, col,
+			tview.NewTableCell(h).
+				SetAlign(tview.AlignCenter).
+				SetSelectable(false).
+				SetAttributes(tcell.AttrBold))
+	}
+
+	now := time.Now()
+	for i, s := range statuses {
+		row := i + 1
+		color := tcell.GetColor(s.color(now, interval))
+		table.SetCell(row, 0, tview.NewTableCell("● "+s.Name).SetTextColor(color))
+		table.SetCell(row, 1, tview.NewTableCell(transportName(s.Config)))
+		table.SetCell(row, 2, tview.NewTableCell(serviceEndpoint(s.Config)))
+		table.SetCell(row, 3, tview.NewTableCell(formatProcess(s)))
+		table.SetCell(row, 4, tview.NewTableCell(formatHeartbeat(s, now)))
+		table.SetCell(row, 5, tview.NewTableCell(formatLatency(s.LastLatency)).SetAlign(tview.AlignRight))
+		table.SetCell(row, 6, tview.NewTableCell(formatLastError(s)))
+	}
+}
+
+func transportName(cfg ServiceConfig) string {
+	if cfg.Transport == "" {
+		return "file"
+	}
+	return cfg.Transport
+}
+
+func serviceEndpoint(cfg ServiceConfig) string {
+	switch transportName(cfg) {
+	case "http":
+		return cfg.URL
+	case "inprocess":
+		return "handler " + cfg.Handler
+	case "spool":
+		return cfg.inboxPath() + " → " + cfg.outboxPath()
+	case "unix":
+		return cfg.socketPath()
+	default:
+		return cfg.inputPath() + " → " + cfg.outputPath()
+	}
+}
+
+func formatProcess(s ServiceStatus) string {
+	if s.Process == "" {
+		return "not supervised"
+	}
+	return s.Process
+}
+
+func formatHeartbeat(s ServiceStatus, now time.Time) string {
+	if !s.monitored() {
+		return "not configured"
+	}
+	if s.LastHeartbeat.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s ago", now.Sub(s.LastHeartbeat).Truncate(time.Second))
+}
+
+func formatLatency(latency time.Duration) string {
+	if latency == 0 {
+		return "-"
+	}
+	return latency.Round(time.Millisecond).String()
+}
+
+func formatLastError(s ServiceStatus) string {
+	if s.LastError == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s (%s)", s.LastError, s.LastErrorAt.Format("15:04:05"))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = 30 * time.Second
+	stableRunTime     = 30 * time.Second
+	stopGracePeriod   = 3 * time.Second
+)
+
+// supervisor launches the microservices that have a command configured,
+// restarts them with exponential backoff when they exit and stops them with
+// an interrupt (then a kill after stopGracePeriod) on shutdown.
+type supervisor struct {
+	logs   *logBuffer
+	board  *statusBoard
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newSupervisor(logs *logBuffer, board *statusBoard) *supervisor {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &supervisor{logs: logs, board: board, ctx: ctx, cancel: cancel}
+}
+
+func (s *supervisor) start(cfg Config) {
+	for _, name := range serviceNames {
+		svc := cfg.Services[name]
+		if len(svc.Command) == 0 {
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.run(name, svc)
+		}()
+	}
+}
+
+// stop interrupts every child process and waits for them to exit.
+func (s *supervisor) stop() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *supervisor) run(name string, svc ServiceConfig) {
+	backoff := minRestartBackoff
+	for {
+		started := time.Now()
+		err := s.runOnce(name, svc)
+		if s.ctx.Err() != nil {
+			s.setProcess(name, "stopped")
+			s.logs.add(name, "stopped")
+			return
+		}
+
+		if time.Since(started) > stableRunTime {
+			backoff = minRestartBackoff
+		}
+		s.setProcess(name, fmt.Sprintf("restarting in %s", backoff))
+		s.logs.add(name, fmt.Sprintf("exited (%v), restarting in %s", err, backoff))
+
+		select {
+		case <-s.ctx.Done():
+			s.setProcess(name, "stopped")
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxRestartBackoff)
+	}
+}
+
+func (s *supervisor) runOnce(name string, svc ServiceConfig) error {
+	cmd := exec.CommandContext(s.ctx, svc.Command[0], svc.Command[1:]...)
+	cmd.Dir = svc.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = svc.Root
+	}
+	cmd.Env = os.Environ()
+	keys := make([]string, 0, len(svc.Env))
+	for k := range svc.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmd.Env = append(cmd.Env, k+"="+svc.Env[k])
+	}
+	cmd.Stdout = &lineWriter{emit: func(line string) { s.logs.add(name, line) }}
+	cmd.Stderr = &lineWriter{emit: func(line string) { s.logs.add(name, "stderr: "+line) }}
+	// The child gets a process group of its own, so that stopping it also
+	// stops whatever it started, such as the real service behind a shell
+	// script or go run
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		time.AfterFunc(stopGracePeriod, func() { killProcessGroup(cmd.Process) })
+		return interruptProcessGroup(cmd.Process)
+	}
+	cmd.WaitDelay = stopGracePeriod
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	s.setProcess(name, fmt.Sprintf("running (pid %d)", cmd.Process.Pid))
+	s.logs.add(name, fmt.Sprintf("started %s (pid %d)", strings.Join(svc.Command, " "), cmd.Process.Pid))
+	return cmd.Wait()
+}
+
+func (s *supervisor) setProcess(name, state string) {
+	s.board.update(name, func(status *ServiceStatus) {
+		status.Process = state
+	})
+}
+
+// lineWriter hands every complete line written to it to emit.
+type lineWriter struct {
+	mu      sync.Mutex
+	pending []byte
+	emit    func(string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, p...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		w.emit(strings.TrimRight(string(w.pending[:i]), "\r"))
+		w.pending = w.pending[i+1:]
+	}
+}
+
+// logBuffer keeps the most recent lines logged by the supervised services.
+// onChange is called after every new line and must not block.
+type logBuffer struct {
+	mu       sync.Mutex
+	lines    []string
+	limit    int
+	onChange func()
+}
+
+func newLogBuffer(limit int) *logBuffer {
+	return &logBuffer{limit: limit}
+}
+
+func (b *logBuffer) add(service, line string) {
+	b.mu.Lock()
+	b.lines = append(b.lines, fmt.Sprintf("%s [%s] %s", time.Now().Format("15:04:05"), service, line))
+	if len(b.lines) > b.limit {
+		b.lines = b.lines[len(b.lines)-b.limit:]
+	}
+	onChange := b.onChange
+	b.mu.Unlock()
+
+	if onChange != nil {
+		onChange()
+	}
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Join(b.lines, "\n")
+}
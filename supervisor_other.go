@@ -0,0 +1,18 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func interruptProcessGroup(p *os.Process) error {
+	return p.Signal(os.Interrupt)
+}
+
+func killProcessGroup(p *os.Process) {
+	p.Kill()
+}
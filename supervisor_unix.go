@@ -0,0 +1,23 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func interruptProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGINT)
+}
+
+// killProcessGroup kills what is left of the group once the grace period is
+// over
+func killProcessGroup(p *os.Process) {
+	syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// SYMBOL DIRECTORY
+// A local list of tickers and company names, read from a CSV file with the
+// columns ticker, name, exchange and type. It completes tickers as they are
+// typed, finds tickers by company name and suggests tickers when
+// microservice-c does not know one.
+
+//go:embed data/symbols.csv
+var defaultSymbols []byte
+
+// tickerPattern is what a ticker may look like, e.g. AAPL, BRK.B or ^GSPC
+var tickerPattern = regexp.MustCompile(`^\^?[A-Z0-9][A-Z0-9.\-]{0,9}$`)
+
+type symbol struct {
+	Ticker   string
+	Name     string
+	Exchange string
+	Type     string
+}
+
+type symbolDirectory struct {
+	symbols  []symbol
+	byTicker map[string]symbol
+}
+
+// loadSymbols reads the directory at path, or the built-in one if path is
+// empty.
+func loadSymbols(path string) (*symbolDirectory, error) {
+	if path == "" {
+		return parseSymbols(bytes.NewReader(defaultSymbols))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir, err := parseSymbols(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return dir, nil
+}
+
+// parseSymbols reads a CSV whose header names its columns, in any order.
+// Only ticker and name are required.
+func parseSymbols(r io.Reader) (*symbolDirectory, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"ticker", "name"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("missing %q column", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	dir := &symbolDirectory{byTicker: make(map[string]symbol)}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		s := symbol{
+			Ticker:   strings.ToUpper(field(record, "ticker")),
+			Name:     field(record, "name"),
+			Exchange: field(record, "exchange"),
+			Type:     field(record, "type"),
+		}
+		if !tickerPattern.MatchString(s.Ticker) {
+			line, _ := reader.FieldPos(0)
+			return nil, fmt.Errorf("line %d: %q is not a ticker", line, s.Ticker)
+		}
+		if _, ok := dir.byTicker[s.Ticker]; ok {
+			continue
+		}
+		dir.symbols = append(dir.symbols, s)
+		dir.byTicker[s.Ticker] = s
+	}
+	slices.SortFunc(dir.symbols, func(a, b symbol) int { return strings.Compare(a.Ticker, b.Ticker) })
+	return dir, nil
+}
+
+func (d *symbolDirectory) lookup(ticker string) (symbol, bool) {
+	s, ok := d.byTicker[strings.ToUpper(ticker)]
+	return s, ok
+}
+
+// complete lists the symbols whose ticker starts with prefix
+func (d *symbolDirectory) complete(prefix string) []symbol {
+	prefix = strings.ToUpper(prefix)
+	var matches []symbol
+	for _, s := range d.symbols {
+		if strings.HasPrefix(s.Ticker, prefix) {
+			matches = append(matches, s)
+		}
+	}
+	return matches
+}
+
+// search ranks the symbols matching query, best first: the ticker itself,
+// then names that start with it, have a word that starts with it, contain it
+// or contain its letters in order.
+func (d *symbolDirectory) search(query string) []symbol {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	type match struct {
+		symbol
+		score int
+	}
+	var matches []match
+	for _, s := range d.symbols {
+		if score := matchScore(s, query); score > 0 {
+			matches = append(matches, match{s, score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b match) int { return b.score - a.score })
+	results := make([]symbol, len(matches))
+	for i, m := range matches {
+		results[i] = m.symbol
+	}
+	return results
+}
+
+// nameMatchScore is the lowest score of a name, or a word of it, starting
+// with the query
+const nameMatchScore = 70
+
+func matchScore(s symbol, query string) int {
+	name := strings.ToLower(s.Name)
+	switch {
+	case strings.ToLower(s.Ticker) == query:
+		return 100
+	case strings.HasPrefix(name, query):
+		return 80
+	case slices.ContainsFunc(strings.FieldsFunc(name, isNameSeparator), func(word string) bool { return strings.HasPrefix(word, query) }):
+		return nameMatchScore
+	case strings.HasPrefix(strings.ToLower(s.Ticker), query):
+		return 60
+	case strings.Contains(name, query):
+		return 50
+	case isSubsequence(query, name):
+		return 20
+	}
+	return 0
+}
+
+func isNameSeparator(r rune) bool {
+	return r == ' ' || r == '-' || r == '.' || r == ',' || r == '&'
+}
+
+func isSubsequence(query, text string) bool {
+	for _, r := range text {
+		if len(query) == 0 {
+			break
+		}
+		if strings.HasPrefix(query, string(r)) {
+			query = query[len(string(r)):]
+		}
+	}
+	return len(query) == 0
+}
+
+// resolve turns one search term into a ticker. "$AAPL" only has to look like
+// a ticker, since the directory does not list every stock. A bare word is a
+// ticker the directory lists, else a company whose name or one of its words
+// starts with it, else sent on as a ticker if it looks like one; note tells
+// which company a name resolved to.
+func (d *symbolDirectory) resolve(term string) (ticker, note string, err error) {
+	if ticker, ok := strings.CutPrefix(term, "$"); ok {
+		ticker = strings.ToUpper(ticker)
+		if !tickerPattern.MatchString(ticker) {
+			return "", "", fmt.Errorf("%q is not a ticker", term)
+		}
+		return ticker, "", nil
+	}
+	if s, ok := d.lookup(term); ok {
+		return s.Ticker, "", nil
+	}
+	if matches := d.search(term); len(matches) > 0 && matchScore(matches[0], strings.ToLower(term)) >= nameMatchScore {
+		return matches[0].Ticker, fmt.Sprintf("%s is %s (%s)", term, matches[0].Ticker, matches[0].Name), nil
+	}
+	if ticker = strings.ToUpper(term); tickerPattern.MatchString(ticker) {
+		return ticker, "", nil
+	}
+	return "", "", fmt.Errorf("no company or ticker matches %q", term)
+}
+
+// resolveAll resolves every term, keeping the order and dropping repeats. The
+// notes tell which company each name resolved to and why a term could not be
+// resolved.
+func (d *symbolDirectory) resolveAll(terms []string) ([]string, []string) {
+	var tickers, notes []string
+	for _, term := range terms {
+		ticker, note, err := d.resolve(term)
+		if err != nil {
+			notes = append(notes, err.Error())
+			continue
+		}
+		if note != "" {
+			notes = append(notes, note)
+		}
+		tickers = append(tickers, ticker)
+	}
+	return normalizeTickers(tickers), notes
+}
+
+// didYouMean adds suggestions to err, the service's answer for ticker, when
+// the service refused the ticker and the directory does not know it either
+func (d *symbolDirectory) didYouMean(ticker string, err error) string {
+	var serviceErr *ServiceError
+	if _, ok := d.lookup(ticker); ok || !errors.As(err, &serviceErr) {
+		return err.Error()
+	}
+	if suggestions := d.suggest(strings.ToUpper(ticker)); suggestions != "" {
+		return fmt.Sprintf("%v, did you mean %s?", err, suggestions)
+	}
+	return err.Error()
+}
+
+// suggest names up to three tickers close to an unknown one: those sharing
+// the longest prefix with it, or else the best matches by name
+func (d *symbolDirectory) suggest(ticker string) string {
+	var matches []symbol
+	for n := len(ticker) - 1; n > 0 && len(matches) == 0; n-- {
+		matches = d.complete(ticker[:n])
+	}
+	if len(matches) == 0 {
+		matches = d.search(ticker)
+	}
+	var suggestions []string
+	for _, s := range matches {
+		suggestions = append(suggestions, "$"+s.Ticker)
+		if len(suggestions) == 3 {
+			break
+		}
+	}
+	return strings.Join(suggestions, ", ")
+}
+
+// completeTickers offers completions for the last word of an input field
+// whose text starts with one of commands: tickers for "$AA", tickers by
+// company name for anything else. Tab or Enter on an entry replaces the word
+// with the ticker.
+func completeTickers(input *tview.InputField, d *symbolDirectory, commands ...string) {
+	lastWord := func(text string) (string, string, bool) {
+		fields := strings.Fields(text)
+		if len(fields) < 2 || strings.HasSuffix(text, " ") || !slices.Contains(commands, strings.ToLower(fields[0])) {
+			return "", "", false
+		}
+		word := fields[len(fields)-1]
+		return strings.TrimSuffix(text, word), word, true
+	}
+
+	input.SetAutocompleteFunc(func(text string) []string {
+		_, word, ok := lastWord(text)
+		if _, known := d.lookup(strings.TrimPrefix(word, "$")); !ok || known {
+			return nil
+		}
+		var matches []symbol
+		if prefix, isTicker := strings.CutPrefix(word, "$"); isTicker {
+			if prefix == "" {
+				return nil
+			}
+			matches = d.complete(prefix)
+		} else if len(word) >= 2 {
+			matches = d.search(word)
+		}
+		var entries []string
+		for _, s := range matches[:min(len(matches), 8)] {
+			entries = append(entries, tview.Escape(fmt.Sprintf("$%-6s %s", s.Ticker, s.Name)))
+		}
+		return entries
+	})
+	input.SetAutocompletedFunc(func(entry string, index, source int) bool {
+		if source == tview.AutocompletedNavigate {
+			return false
+		}
+		if rest, _, ok := lastWord(input.GetText()); ok {
+			input.SetText(rest + strings.Fields(entry)[0] + " ")
+		}
+		return true
+	})
+}
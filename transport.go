@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Transport carries a single JSON request to a microservice and returns the
+// JSON it answered with. Responses that do not echo the request ID are never
+// returned. Exchange gives up with ctx.Err() once ctx is done.
+type Transport interface {
+	Exchange(ctx context.Context, request Request) ([]byte, error)
+}
+
+// FILE DROP TRANSPORT
+
+// fileTransport writes the request to inputPath and waits for the response at
+// outputPath. With sentinel set, a zero-length "<file>.done" marks each file
+// as complete: we create one after writing the input and wait for the
+// service's one before reading the output. There is only one pair of files,
+// so the Service gives exchanges their turn one at a time.
+type fileTransport struct {
+	inputPath  string
+	outputPath string
+	sentinel   bool
+	watcher    watcher
+}
+
+func (t *fileTransport) Exchange(ctx context.Context, request Request) ([]byte, error) {
+	ready := t.outputPath
+	if t.sentinel {
+		ready = sentinelPath(t.outputPath)
+	}
+
+	// Subscribe before writing the input so a fast answer is not missed
+	changed, unwatch, err := t.watcher.watch(ready)
+	if err != nil {
+		return nil, err
+	}
+	defer unwatch()
+
+	t.removeOutput()
+	if err := t.writeInput(request.Body); err != nil {
+		return nil, err
+	}
+
+	for {
+		if err := waitForFile(ctx, ready, changed); err != nil {
+			return nil, err
+		}
+		data, err := readCompleteJSON(ctx, t.outputPath, changed)
+		if err != nil {
+			return nil, err
+		}
+
+		// Drop answers to earlier requests and keep waiting for ours
+		var stale *StaleResponseError
+		if errors.As(checkResponseID(data, request.ID), &stale) {
+			t.removeOutput()
+			request.reportStale(stale)
+			continue
+		}
+		if t.sentinel {
+			os.Remove(ready)
+		}
+		return data, nil
+	}
+}
+
+func (t *fileTransport) writeInput(body []byte) error {
+	if err := writeFileAtomic(t.inputPath, body, 0644); err != nil {
+		return err
+	}
+	if t.sentinel {
+		return writeFileAtomic(sentinelPath(t.inputPath), nil, 0644)
+	}
+	return nil
+}
+
+func (t *fileTransport) removeOutput() {
+	os.Remove(t.outputPath)
+	if t.sentinel {
+		os.Remove(sentinelPath(t.outputPath))
+	}
+}
+
+// SPOOL TRANSPORT
+
+// spoolTransport writes each request to its own "<request_id>.json" in inbox
+// and waits for the file of the same name in outbox, so any number of
+// requests can be in flight at once. Sentinels work as for fileTransport.
+type spoolTransport struct {
+	inbox    string
+	outbox   string
+	sentinel bool
+	watcher  watcher
+}
+
+func (t *spoolTransport) Exchange(ctx context.Context, request Request) ([]byte, error) {
+	name := request.ID + ".json"
+	inputPath := filepath.Join(t.inbox, name)
+	outputPath := filepath.Join(t.outbox, name)
+	ready := outputPath
+	if t.sentinel {
+		ready = sentinelPath(outputPath)
+	}
+
+	changed, unwatch, err := t.watcher.watch(ready)
+	if err != nil {
+		return nil, err
+	}
+	defer unwatch()
+
+	if err := writeFileAtomic(inputPath, request.Body, 0644); err != nil {
+		return nil, err
+	}
+	if t.sentinel {
+		if err := writeFileAtomic(sentinelPath(inputPath), nil, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := waitForFile(ctx, ready, changed); err != nil {
+		// Withdraw the request if the service has not picked it up yet
+		os.Remove(inputPath)
+		os.Remove(sentinelPath(inputPath))
+		return nil, err
+	}
+	data, err := readCompleteJSON(ctx, outputPath, changed)
+	if err != nil {
+		return nil, err
+	}
+	os.Remove(outputPath)
+	if t.sentinel {
+		os.Remove(ready)
+	}
+
+	if err := checkResponseID(data, request.ID); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// HTTP TRANSPORT
+type httpTransport struct {
+	url    string
+	client *http.Client
+}
+
+func (t *httpTransport) Exchange(ctx context.Context, request Request) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(request.Body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", t.url, resp.Status)
+	}
+	if err := checkResponseID(body, request.ID); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// IN-PROCESS TRANSPORT
+type Handler func(request []byte) ([]byte, error)
+
+var handlers = make(map[string]Handler)
+
+func registerHandler(name string, handler Handler) {
+	handlers[name] = handler
+}
+
+type inProcessTransport struct {
+	handler Handler
+}
+
+func (t *inProcessTransport) Exchange(ctx context.Context, request Request) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	response, err := t.handler(request.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponseID(response, request.ID); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func newTransport(name string, cfg ServiceConfig) (Transport, error) {
+	switch cfg.Transport {
+	case "", "file":
+		return &fileTransport{
+			inputPath:  cfg.inputPath(),
+			outputPath: cfg.outputPath(),
+			sentinel:   cfg.Sentinel,
+			watcher:    sharedWatcher(),
+		}, nil
+	case "spool":
+		return &spoolTransport{
+			inbox:    cfg.inboxPath(),
+			outbox:   cfg.outboxPath(),
+			sentinel: cfg.Sentinel,
+			watcher:  sharedWatcher(),
+		}, nil
+	case "unix":
+		if cfg.Socket == "" {
+			return nil, errNoSocket
+		}
+		method := cfg.Method
+		if method == "" {
+			method = name
+		}
+		return newSocketTransport(cfg.socketPath(), method), nil
+	case "http":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("http transport requires a url")
+		}
+		return &httpTransport{url: cfg.URL, client: &http.Client{}}, nil
+	case "inprocess":
+		handler, ok := handlers[cfg.Handler]
+		if !ok {
+			return nil, fmt.Errorf("no in-process handler named %q", cfg.Handler)
+		}
+		return &inProcessTransport{handler: handler}, nil
+	default:
+		return nil, fmt.Errorf("unknown transport %q", cfg.Transport)
+	}
+}
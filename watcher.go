@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const pollInterval = 500 * time.Millisecond
+
+// watcher notifies subscribers that a file may have been written. The channel
+// returned by watch is buffered so a notification that arrives while nobody is
+// receiving is kept rather than lost.
+type watcher interface {
+	watch(path string) (<-chan struct{}, func(), error)
+}
+
+var (
+	sharedWatcherOnce sync.Once
+	sharedWatcherInst watcher
+)
+
+// sharedWatcher returns the process-wide watcher used by every file-based
+// transport: the platform's event-driven watcher when available, polling
+// otherwise.
+func sharedWatcher() watcher {
+	sharedWatcherOnce.Do(func() {
+		w, err := newPlatformWatcher()
+		if err != nil {
+			sharedWatcherInst = &pollingWatcher{interval: pollInterval}
+			return
+		}
+		sharedWatcherInst = &fallbackWatcher{primary: w, fallback: &pollingWatcher{interval: pollInterval}}
+	})
+	return sharedWatcherInst
+}
+
+// pollingWatcher wakes its subscribers on a fixed interval.
+type pollingWatcher struct {
+	interval time.Duration
+}
+
+func (w *pollingWatcher) watch(path string) (<-chan struct{}, func(), error) {
+	changed := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				notify(changed)
+			}
+		}
+	}()
+	var once sync.Once
+	return changed, func() { once.Do(func() { close(done) }) }, nil
+}
+
+// fallbackWatcher polls paths the primary watcher cannot watch, such as files
+// in a directory that does not exist yet.
+type fallbackWatcher struct {
+	primary  watcher
+	fallback watcher
+}
+
+func (w *fallbackWatcher) watch(path string) (<-chan struct{}, func(), error) {
+	changed, unwatch, err := w.primary.watch(path)
+	if err != nil {
+		return w.fallback.watch(path)
+	}
+	return changed, unwatch, nil
+}
+
+// watcherLogs, if set, receives the watcher's problems, logged under
+// "watcher"
+var watcherLogs *logBuffer
+
+func logWatcher(format string, args ...interface{}) {
+	if watcherLogs != nil {
+		watcherLogs.add("watcher", fmt.Sprintf(format, args...))
+	}
+}
+
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
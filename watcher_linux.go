@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// inotifyWatcher watches the directories containing subscribed files and
+// wakes subscribers when a file is closed after writing or renamed into place.
+// Subscribers whose directory goes away, or all of them if inotify itself
+// fails, are polled instead.
+type inotifyWatcher struct {
+	fd int
+
+	mu     sync.Mutex
+	dirs   map[string]int
+	wds    map[int]string
+	subs   map[string]map[chan struct{}]*inotifySub
+	failed error
+}
+
+type inotifySub struct {
+	done    chan struct{}
+	polling bool
+}
+
+func newPlatformWatcher() (watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	w := &inotifyWatcher{
+		fd:   fd,
+		dirs: make(map[string]int),
+		wds:  make(map[int]string),
+		subs: make(map[string]map[chan struct{}]*inotifySub),
+	}
+	go w.run()
+	return w, nil
+}
+
+func (w *inotifyWatcher) watch(path string) (<-chan struct{}, func(), error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	dir := filepath.Dir(path)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return nil, nil, w.failed
+	}
+	if _, ok := w.dirs[dir]; !ok {
+		wd, err := unix.InotifyAddWatch(w.fd, dir, unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO)
+		if err != nil {
+			return nil, nil, err
+		}
+		w.dirs[dir] = wd
+		w.wds[wd] = dir
+	}
+
+	changed := make(chan struct{}, 1)
+	if w.subs[path] == nil {
+		w.subs[path] = make(map[chan struct{}]*inotifySub)
+	}
+	sub := &inotifySub{done: make(chan struct{})}
+	w.subs[path][changed] = sub
+
+	unwatch := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[path][changed]; ok {
+			close(sub.done)
+		}
+		delete(w.subs[path], changed)
+		if len(w.subs[path]) == 0 {
+			delete(w.subs, path)
+		}
+	}
+	return changed, unwatch, nil
+}
+
+func (w *inotifyWatcher) run() {
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := unix.Read(w.fd, buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil || n <= 0 {
+			if err == nil {
+				err = fmt.Errorf("read returned %d", n)
+			}
+			w.mu.Lock()
+			w.failed = fmt.Errorf("inotify stopped: %w", err)
+			logWatcher("%v, polling instead", w.failed)
+			w.pollSubs("")
+			w.mu.Unlock()
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(event.Len)], "\x00"))
+			offset = nameStart + int(event.Len)
+
+			w.mu.Lock()
+			dir, ok := w.wds[int(event.Wd)]
+			switch {
+			case !ok:
+			case event.Mask&unix.IN_IGNORED != 0:
+				// The directory was removed or renamed; a later watch adds it
+				// again if it comes back
+				delete(w.wds, int(event.Wd))
+				delete(w.dirs, dir)
+				logWatcher("%s is no longer watched, polling instead", dir)
+				w.pollSubs(dir)
+			case name != "":
+				for ch := range w.subs[filepath.Join(dir, name)] {
+					notify(ch)
+				}
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// pollSubs switches the subscribers to files in dir, or to every file if dir
+// is empty, to polling. w.mu must be held.
+func (w *inotifyWatcher) pollSubs(dir string) {
+	for path, subs := range w.subs {
+		if dir != "" && filepath.Dir(path) != dir {
+			continue
+		}
+		for ch, sub := range subs {
+			if sub.polling {
+				continue
+			}
+			sub.polling = true
+			notify(ch)
+			go func() {
+				ticker := time.NewTicker(pollInterval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-sub.done:
+						return
+					case <-ticker.C:
+						notify(ch)
+					}
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func newPlatformWatcher() (watcher, error) {
+	return nil, errors.New("no event-driven file watcher on this platform")
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// WATCHLISTS
+// Named lists of tickers, saved in watchlists.json next to the config file.
+// One list is open at a time and is refreshed in the background.
+
+const defaultWatchlist = "default"
+
+type watchlists struct {
+	Current string              `json:"current"`
+	Lists   map[string][]string `json:"lists"`
+}
+
+func loadWatchlists(path string) (*watchlists, error) {
+	w := &watchlists{}
+	if _, err := loadState(path, w); err != nil {
+		return nil, err
+	}
+	if w.Lists == nil {
+		w.Lists = make(map[string][]string)
+	}
+	if w.Current == "" {
+		w.Current = defaultWatchlist
+	}
+	return w, nil
+}
+
+// tickers of the open list
+func (w *watchlists) tickers() []string {
+	return w.Lists[w.Current]
+}
+
+// names of every list, including the open one even while it is empty
+func (w *watchlists) names() []string {
+	names := []string{w.Current}
+	for name := range w.Lists {
+		if name != w.Current {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names[1:])
+	return names
+}
+
+func (w *watchlists) open(name string) {
+	w.Current = strings.ToLower(name)
+}
+
+// add appends the tickers not already on the list and returns them
+func (w *watchlists) add(name string, tickers []string) []string {
+	var added []string
+	for _, ticker := range normalizeTickers(tickers) {
+		if !slices.Contains(w.Lists[name], ticker) {
+			w.Lists[name] = append(w.Lists[name], ticker)
+			added = append(added, ticker)
+		}
+	}
+	return added
+}
+
+// remove takes the tickers off the list and returns the ones that were on it
+func (w *watchlists) remove(name string, tickers []string) []string {
+	var removed []string
+	for _, ticker := range normalizeTickers(tickers) {
+		if i := slices.Index(w.Lists[name], ticker); i >= 0 {
+			w.Lists[name] = slices.Delete(w.Lists[name], i, i+1)
+			removed = append(removed, ticker)
+		}
+	}
+	if len(w.Lists[name]) == 0 {
+		delete(w.Lists, name)
+	}
+	return removed
+}
+
+// delete drops a whole list. Deleting the open list opens the default one.
+func (w *watchlists) delete(name string) bool {
+	_, ok := w.Lists[name]
+	delete(w.Lists, name)
+	if name == w.Current {
+		w.Current = defaultWatchlist
+	}
+	return ok
+}
+
+// watchQuote is the latest quote of a watched ticker and when it arrived.
+// Err is set if the last refresh of the ticker failed.
+type watchQuote struct {
+	StockData
+	Updated time.Time
+	Err     error
+}
+
+func renderWatchlistTable(table *tview.Table, tickers []string, quotes map[string]watchQuote) {
+	table.Clear()
+
+	headers := []string{"Ticker", "Last", "Change", "Change %", "Date", "Session", "Updated"}
+	for col, header := range headers {
+		table.SetCell(0, col,
+			tview.NewTableCell(header).
+				SetAlign(tview.AlignCenter).
+				SetSelectable(false))
+	}
+
+	now := time.Now()
+	for i, ticker := range tickers {
+		row := i + 1
+		table.SetCell(row, 0, tview.NewTableCell(ticker))
+		quote, ok := quotes[ticker]
+		if !ok || quote.Updated.IsZero() {
+			status := "waiting..."
+			if ok && quote.Err != nil {
+				status = "failed"
+			}
+			table.SetCell(row, 6, tview.NewTableCell(status).SetTextColor(tcell.ColorGray))
+			continue
+		}
+
+		color := tcell.ColorDefault
+		if change := stockChange(quote.StockData); change > 0 {
+			color = tcell.ColorGreen
+		} else if change < 0 {
+			color = tcell.ColorRed
+		}
+		table.SetCell(row, 1, tview.NewTableCell(fmt.Sprintf("%.2f", quote.Close)).SetAlign(tview.AlignRight))
+		table.SetCell(row, 2, tview.NewTableCell(fmt.Sprintf("%+.2f", stockChange(quote.StockData))).SetAlign(tview.AlignRight).SetTextColor(color))
+		table.SetCell(row, 3, tview.NewTableCell(fmt.Sprintf("%+.2f%%", stockChangePercent(quote.StockData))).SetAlign(tview.AlignRight).SetTextColor(color))
+		table.SetCell(row, 4, tview.NewTableCell(quote.Date))
+		table.SetCell(row, 5, tview.NewTableCell(usMarket.quoteSession(quote.Date, now).String()))
+
+		updated := tview.NewTableCell(quote.Updated.Format("15:04:05"))
+		if quote.Err != nil {
+			updated.SetText(quote.Updated.Format("15:04:05") + " (refresh failed)").SetTextColor(tcell.ColorRed)
+		}
+		table.SetCell(row, 6, updated)
+	}
+}